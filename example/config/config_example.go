@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/chhz0/going/pkg/config"
@@ -25,8 +26,7 @@ type Mysql struct {
 }
 
 func main() {
-	conf := Config{}
-	cv, err := config.NewConfigV(conf)
+	cv, err := config.NewConfigV[Config]()
 	if err != nil {
 		panic(err)
 	}
@@ -36,9 +36,12 @@ func main() {
 		panic(err)
 	}
 
-	cv.Watch()
+	if _, err := cv.Watch(context.Background()); err != nil {
+		panic(err)
+	}
 
 	for {
+		conf := cv.Get()
 		fmt.Println(conf.Mysql.Url)
 	}
 }
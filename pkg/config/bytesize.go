@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a size in bytes that decodes from strings with a unit, e.g.
+// "512KB", "10MB" or "1.5GiB". Decimal units (KB, MB, GB, TB, PB) are powers
+// of 1000 and binary units (KiB, MiB, GiB, TiB, PiB) powers of 1024; units
+// are case-insensitive and the trailing B may be left out. A number without
+// a unit is a number of bytes.
+type ByteSize uint64
+
+// Byte size units.
+const (
+	B   ByteSize = 1
+	KB  ByteSize = 1000 * B
+	MB  ByteSize = 1000 * KB
+	GB  ByteSize = 1000 * MB
+	TB  ByteSize = 1000 * GB
+	PB  ByteSize = 1000 * TB
+	KiB ByteSize = 1024 * B
+	MiB ByteSize = 1024 * KiB
+	GiB ByteSize = 1024 * MiB
+	TiB ByteSize = 1024 * GiB
+	PiB ByteSize = 1024 * TiB
+)
+
+type byteUnit struct {
+	name string
+	size ByteSize
+}
+
+var byteSizeType = reflect.TypeFor[ByteSize]()
+
+// byteUnits lists the units from largest to smallest, binary first, which
+// is the order String picks them in.
+var byteUnits = []byteUnit{
+	{"PiB", PiB}, {"PB", PB}, {"TiB", TiB}, {"TB", TB}, {"GiB", GiB}, {"GB", GB},
+	{"MiB", MiB}, {"MB", MB}, {"KiB", KiB}, {"KB", KB}, {"B", B},
+}
+
+var byteSizePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]*)$`)
+
+// ParseByteSize parses a byte size such as "10MB", see ByteSize.
+func ParseByteSize(s string) (ByteSize, error) {
+	m := byteSizePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid byte size '%s'", s)
+	}
+
+	unit := m[2]
+	if unit != "" && !strings.HasSuffix(strings.ToLower(unit), "b") {
+		unit += "B"
+	}
+	size := B
+	if unit != "" {
+		i := slices.IndexFunc(byteUnits, func(u byteUnit) bool { return strings.EqualFold(u.name, unit) })
+		if i < 0 {
+			return 0, fmt.Errorf("invalid byte size '%s': unknown unit '%s'", s, m[2])
+		}
+		size = byteUnits[i].size
+	}
+
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size '%s': %w", s, err)
+	}
+	bytes := n * float64(size)
+	if bytes >= math.MaxUint64 {
+		return 0, fmt.Errorf("invalid byte size '%s': out of range", s)
+	}
+	return ByteSize(bytes), nil
+}
+
+// String formats b with the largest unit it is a whole multiple of, e.g.
+// "10MB" or "512KiB".
+func (b ByteSize) String() string {
+	for _, u := range byteUnits {
+		if b != 0 && b%u.size == 0 {
+			return strconv.FormatUint(uint64(b/u.size), 10) + u.name
+		}
+	}
+	return "0B"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
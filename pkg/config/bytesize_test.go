@@ -0,0 +1,57 @@
+package config
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ByteSize
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"512B", 512, false},
+		{"10MB", 10 * MB, false},
+		{"10mb", 10 * MB, false},
+		{"10M", 10 * MB, false},
+		{"64 KiB", 64 * KiB, false},
+		{"1.5GiB", 3 * GiB / 2, false},
+		{"2Ti", 2 * TiB, false},
+		{"", 0, true},
+		{"MB", 0, true},
+		{"-1MB", 0, true},
+		{"10XB", 0, true},
+		{"100000PB", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseByteSize(%q) = %d, %v, want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestByteSize_String(t *testing.T) {
+	tests := []struct {
+		in   ByteSize
+		want string
+	}{
+		{0, "0B"},
+		{1, "1B"},
+		{1500, "1500B"},
+		{2 * KB, "2KB"},
+		{512 * KiB, "512KiB"},
+		{10 * MB, "10MB"},
+		{3 * GiB, "3GiB"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("ByteSize(%d).String() = %q, want %q", uint64(tt.in), got, tt.want)
+		}
+		if parsed, err := ParseByteSize(tt.in.String()); err != nil || parsed != tt.in {
+			t.Errorf("ParseByteSize(%q) = %d, %v, want %d", tt.in.String(), parsed, err, uint64(tt.in))
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+)
+
+// NewExplainCommand returns a cobra command that prints the effective
+// configuration held by c, annotated with the source of every key and with
+// secrets redacted.
+func NewExplainCommand[T any](c *ConfigV[T]) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "explain",
+		Short: "Print the effective configuration and where each value came from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return c.Dump(cmd.OutOrStdout(), output)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format, one of: yaml, json, table")
+
+	return cmd
+}
+
+// NewMigrateCommand returns a cobra command that rewrites config files to
+// the latest version using the migrations added to c, and reports the
+// deprecated keys they still use.
+func NewMigrateCommand[T any](c *ConfigV[T]) *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate FILE...",
+		Short: "Rewrite config files to the latest version and report deprecated keys",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, file := range args {
+				report, err := c.MigrateFile(file, dryRun)
+				if err != nil {
+					return err
+				}
+
+				out := cmd.OutOrStdout()
+				switch {
+				case report.From == report.To:
+					fmt.Fprintf(out, "%s: already at version %d\n", file, report.To)
+				case dryRun:
+					fmt.Fprintf(out, "%s: would migrate from version %d to %d\n", file, report.From, report.To)
+				default:
+					fmt.Fprintf(out, "%s: migrated from version %d to %d\n", file, report.From, report.To)
+				}
+				for _, d := range report.Deprecated {
+					fmt.Fprintf(out, "%s: %s\n", file, d)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only report, do not rewrite the files")
+
+	return cmd
+}
+
+// NewDiffCommand returns a cobra command that prints the keys whose
+// effective values differ between two config files, or between two profiles
+// or environments of one file, with secrets redacted. newConfig must return
+// a ConfigV set up like the application's, see Diff.
+func NewDiffCommand[T any](newConfig func() (*ConfigV[T], error)) *cobra.Command {
+	var (
+		output   string
+		from, to DiffTarget
+	)
+
+	cmd := &cobra.Command{
+		Use:   "diff FILE [FILE]",
+		Short: "Compare the effective values of two configurations",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from.File, to.File = args[0], args[len(args)-1]
+			diffs, err := Diff(newConfig, from, to)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			switch output {
+			case "json":
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(diffs)
+			case "table":
+				if len(diffs) == 0 {
+					fmt.Fprintln(out, "no differences")
+					return nil
+				}
+				table := uitable.New()
+				table.MaxColWidth = 80
+				table.AddRow("KEY", "FROM", "TO")
+				for _, d := range diffs {
+					table.AddRow(d.Key, fmt.Sprint(d.From), fmt.Sprint(d.To))
+				}
+				_, err := fmt.Fprintln(out, table.String())
+				return err
+			default:
+				return fmt.Errorf("unsupported format '%s', want json or table", output)
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format, one of: json, table")
+	cmd.Flags().StringVar(&from.Profile, "from-profile", "", "profile of the first configuration")
+	cmd.Flags().StringVar(&to.Profile, "to-profile", "", "profile of the second configuration")
+	cmd.Flags().StringToStringVar(&from.Env, "from-env", nil, "environment variables of the first configuration, e.g. APP_HTTP_PORT=80")
+	cmd.Flags().StringToStringVar(&to.Env, "to-env", nil, "environment variables of the second configuration")
+
+	return cmd
+}
+
+// NewCryptCommand returns a cobra command to manage encrypted config values
+// with the subcommands keygen, encrypt, decrypt and rotate. The key is read
+// from --key-file or, by default, from the environment variable --key-env.
+func NewCryptCommand() *cobra.Command {
+	var keyEnv, keyFile string
+
+	loadKey := func(env, file string) (*Cipher, error) {
+		var (
+			key []byte
+			err error
+		)
+		if file != "" {
+			key, err = KeyFromFile(file)
+		} else {
+			key, err = KeyFromEnv(env)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return NewCipher(key)
+	}
+
+	cmd := &cobra.Command{
+		Use:   "crypt",
+		Short: "Encrypt, decrypt and rotate encrypted config values",
+	}
+	cmd.PersistentFlags().StringVar(&keyEnv, "key-env", DefaultKeyEnv, "environment variable holding the base64 encoded key")
+	cmd.PersistentFlags().StringVar(&keyFile, "key-file", "", "file holding the base64 encoded key, takes precedence over --key-env")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "keygen",
+		Short: "Generate a new base64 encoded key",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			key, err := GenerateKey()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), key)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "encrypt VALUE",
+		Short: "Encrypt a value for use in a config file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ci, err := loadKey(keyEnv, keyFile)
+			if err != nil {
+				return err
+			}
+			value, err := ci.Encrypt(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), value)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "decrypt VALUE",
+		Short: "Decrypt an encrypted config value",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ci, err := loadKey(keyEnv, keyFile)
+			if err != nil {
+				return err
+			}
+			value, err := ci.Decrypt(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), value)
+			return nil
+		},
+	})
+
+	var newKeyEnv, newKeyFile string
+	rotate := &cobra.Command{
+		Use:   "rotate FILE...",
+		Short: "Re-encrypt every encrypted value of config files with a new key",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			oldCipher, err := loadKey(keyEnv, keyFile)
+			if err != nil {
+				return err
+			}
+			newCipher, err := loadKey(newKeyEnv, newKeyFile)
+			if err != nil {
+				return err
+			}
+
+			for _, file := range args {
+				n, err := RotateFile(file, oldCipher, newCipher)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: rotated %d values\n", file, n)
+			}
+			return nil
+		},
+	}
+	rotate.Flags().StringVar(&newKeyEnv, "new-key-env", DefaultKeyEnv+"_NEW", "environment variable holding the new base64 encoded key")
+	rotate.Flags().StringVar(&newKeyFile, "new-key-file", "", "file holding the new base64 encoded key, takes precedence over --new-key-env")
+	cmd.AddCommand(rotate)
+
+	return cmd
+}
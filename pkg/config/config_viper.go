@@ -1,229 +1,229 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
+	"path/filepath"
 	"reflect"
-
-	"github.com/fsnotify/fsnotify"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chhz0/going/pkg/logger/zlog"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
-// ConfigV is based on spf13/viper.
-type ConfigV struct {
+// ConfigV is a concurrency-safe configuration holder based on spf13/viper.
+//
+// The current configuration is kept behind an atomic pointer. Every reload
+// decodes into a freshly allocated T and swaps it in, so a snapshot returned
+// by Get is never modified afterwards and can be read without locking.
+type ConfigV[T any] struct {
 	v *viper.Viper
 
-	// configPath      []string
-	// configName      string
-	// configType      string
-	configUnmarshal any    // ptr must be a pointer.
-	onChange        func() // optional callback for config change.
+	mu       sync.Mutex // serializes reloads.
+	current  atomic.Pointer[T]
+	onChange func(ChangeEvent[T]) // optional callback for config change.
+
+	subscribers subscribers[T]
+	listeners   listeners[T]
+	history     history[T]
+	logger      zlog.Logger // see SetLogger.
+
+	statusMu sync.Mutex
+	status   ReloadStatus
+
+	fs         afero.Fs          // filesystem config files are read from, see SetFs.
+	configFile string            // base config file registered by Load.
+	profile    string            // profile set by SetProfile.
+	profileEnv string            // env var selecting the profile, see SetProfileEnv.
+	sources    []Source          // sources added with AddSource.
+	migrations []Migration       // sorted by From, see AddMigration.
+	keySources map[string]string // key path -> file or source the value came from.
+	schema     *Schema           // validates config files, see SetSchemaValidation.
+	strict     StrictMode        // handling of unknown keys, see SetStrict.
+	files      atomic.Pointer[[]string]
+	filesHash  string        // hash of the config files as last read.
+	missing    []string      // optional files that did not exist when last read.
+	debounce   time.Duration // delay before reloading after a file event.
+
+	tagName    string                        // struct tag naming the config keys, see SetTagName.
+	hooks      []mapstructure.DecodeHookFunc // see AddDecodeHook.
+	envEnabled bool
+	flagSets   []*pflag.FlagSet
+
+	resolvers map[string]Resolver
+	cipher    *Cipher                             // decrypts encrypted values, see SetDecryptionKey.
+	secrets   atomic.Pointer[map[string]struct{}] // keys resolved from references.
+	entries   atomic.Pointer[[]Entry]             // effective config with provenance.
 }
 
-// NewConfigV creates a new ConfigV instance.
-func NewConfigV(ptr any) (*ConfigV, error) {
-	if ptr == nil {
-		return nil, fmt.Errorf("[NewConfigV] the param:ptr cannot be nil.")
+// NewConfigV creates a new ConfigV instance for the struct type T.
+func NewConfigV[T any]() (*ConfigV[T], error) {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("[NewConfigV] the type param:T must be a struct, got %s.", typ)
 	}
 
-	val := reflect.ValueOf(ptr)
-	if val.Kind() != reflect.Pointer {
-		return nil, fmt.Errorf("[NewConfigV] the param:ptr must be a pointer, got %T.", ptr)
+	c := &ConfigV[T]{
+		v:  viper.New(),
+		fs: afero.NewOsFs(),
+		resolvers: map[string]Resolver{
+			"env":  EnvResolver,
+			"file": FileResolver,
+		},
 	}
-
-	return &ConfigV{
-		v:               viper.New(),
-		configUnmarshal: ptr,
-	}, nil
+	c.current.Store(new(T))
+	c.secrets.Store(&map[string]struct{}{})
+	c.entries.Store(&[]Entry{})
+	c.files.Store(&[]string{})
+	c.debounce = defaultDebounce
+	c.history.size = defaultHistorySize
+	c.tagName = detectTagName(typ)
+	c.applyTagDefaults()
+
+	return c, nil
 }
 
-// SetOnChange sets the callback function when the configuration changes.
-func (c *ConfigV) SetOnChange(fn func()) {
+// SetOnChange sets the callback function invoked after a reload changed at
+// least one key. The callback runs synchronously while the reload is still in
+// progress, so it must not call Load or Reload.
+//
+// Only one callback can be set; use Subscribe to register several handlers.
+func (c *ConfigV[T]) SetOnChange(fn func(ChangeEvent[T])) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.onChange = fn
 }
 
-// Load loads the configuration from the specified path.
-func (c *ConfigV) Load(configPath, configName, configType string) error {
-	if configPath != "" {
-		c.v.AddConfigPath(configPath)
+// Get returns the current configuration snapshot.
+//
+// The returned value must be treated as read-only; it is shared by all readers
+// and is replaced, never mutated, when the configuration is reloaded.
+func (c *ConfigV[T]) Get() *T {
+	return c.current.Load()
+}
+
+// Load loads the configuration from the file configName.configType in
+// configPath, together with its includes and the overlay of the active
+// profile, see SetProfile.
+func (c *ConfigV[T]) Load(configPath, configName, configType string) error {
+	file := filepath.Join(configPath, configName+"."+configType)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.fs.Stat(file); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return c.setStatus(fmt.Errorf("[ConfigV.Load] %w.", &NotFoundError{File: file, Err: err}))
+		}
+		return c.setStatus(fmt.Errorf("[ConfigV.Load] failed to read config file: %w.", err))
 	}
 
-	c.v.SetConfigName(configName)
-	c.v.SetConfigType(configType)
+	c.configFile = file
+	if err := c.readConfig(); err != nil {
+		return c.setStatus(fmt.Errorf("[ConfigV.Load] failed to read config file: %w.", err))
+	}
 
-	if err := c.v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			return fmt.Errorf("[ConfigV.Load] config file '%s.%s' not found in search paths:%s.",
-				configName, configType, configPath)
-		}
-		return fmt.Errorf("[ConfigV.Load] failed to read config file: %w.", err)
+	if _, err := c.apply("load"); err != nil {
+		return c.setStatus(fmt.Errorf("[ConfigV.Load] %w", err))
+	}
+
+	return c.setStatus(nil)
+}
+
+// Reload re-reads all configuration layers and atomically swaps in the new
+// value. The previous snapshot is kept if reading or decoding fails or a
+// ReloadListener vetoes the change.
+//
+// Reload can also be used instead of Load to build the configuration without
+// a config file, e.g. from sources, environment variables and flags only.
+func (c *ConfigV[T]) Reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.readConfig(); err != nil {
+		return c.setStatus(fmt.Errorf("[ConfigV.Reload] failed to re-read config: %w.", err))
 	}
 
-	if err := c.v.Unmarshal(c.configUnmarshal); err != nil {
-		return fmt.Errorf("[ConfigV.Load] failed to unmarshal config to struct: %w.", err)
+	event, err := c.apply("reload")
+	if err != nil {
+		return c.setStatus(fmt.Errorf("[ConfigV.Reload] %w", err))
 	}
 
-	return nil
+	if len(event.Keys) > 0 {
+		c.notify(event)
+	}
+
+	return c.setStatus(nil)
 }
 
-// Watch watches for changes to the config file and reloads it.
-func (c *ConfigV) Watch() {
-	c.v.WatchConfig()
-	c.v.OnConfigChange(func(in fsnotify.Event) {
-		if err := c.v.ReadInConfig(); err != nil {
-			log.Printf("[ConfigV.Watch] error re-reading config after change: %v.", err)
-			return
-		}
+// setStatus records the outcome of a load or reload and returns err.
+func (c *ConfigV[T]) setStatus(err error) error {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
 
-		if err := c.v.Unmarshal(c.configUnmarshal); err != nil {
-			log.Printf("[ConfigV.Watch] error unmarshaling new config: %v.", err)
-			return
-		}
+	if err != nil {
+		c.status.Failures++
+		c.status.LastError = err
+		c.status.LastErrorAt = time.Now()
+		return err
+	}
 
-		if c.onChange != nil {
-			c.onChange()
-		}
-	})
+	c.status.Reloads++
+	c.status.LastSuccess = time.Now()
+	return nil
 }
 
 // Viper returns the underlying viper instance.
-func (c *ConfigV) Viper() *viper.Viper {
+func (c *ConfigV[T]) Viper() *viper.Viper {
 	return c.v
 }
 
-// // ConfigVSafe is concurrency-safe.
-// type ConfigVSafe struct {
-// 	rw sync.RWMutex
-// 	v  *viper.Viper
-
-// 	configUnmarshal any
-// 	decoder         func(any) error
-// }
-
-// // NewConfigVSafe create a new thread-safe ConfigVSafe instance.
-// func NewConfigVSafe(ptr any) (*ConfigVSafe, error) {
-// 	if ptr == nil {
-// 		return nil, fmt.Errorf("[NewConfigVSafe] the param:ptr cannot be nil.")
-// 	}
-
-// 	val := reflect.ValueOf(ptr)
-// 	if val.Kind() != reflect.Pointer {
-// 		return nil, fmt.Errorf("[NewConfigVSafe] the param:ptr must be a pointer, got %T.", ptr)
-// 	}
-
-// 	// create a zero value of the pointed-to type
-// 	configType := reflect.TypeOf(ptr).Elem()
-// 	configValue := reflect.New(configType).Elem().Interface()
-
-// 	return &ConfigVSafe{
-// 		v:               viper.New(),
-// 		configUnmarshal: configValue,
-// 		decoder:         func(dst any) error { return nil },
-// 	}, nil
-// }
-
-// // SetDecoder sets a custom decoder function (e.g., json.Unmarshal, yaml.Unmarshal, toml.Unmarshal)
-// func (c *ConfigVSafe) SetDecoder(decoder func(any) error) {
-// 	c.decoder = decoder
-// }
-
-// // Load loads configuration from file
-// func (c *ConfigVSafe) Load(configPath, configName, configType string) error {
-// 	if configPath != "" {
-// 		c.v.AddConfigPath(configPath)
-// 	}
-// 	c.v.SetConfigName(configName)
-// 	c.v.SetConfigType(configType)
-
-// 	if err := c.v.ReadInConfig(); err != nil {
-// 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-// 			return fmt.Errorf("[ConfigVSafe.Load] config file '%s.%s' not found in search paths:%s.",
-// 				configName, configType, configPath)
-// 		}
-// 		return fmt.Errorf("[ConfigVSafe.Load] failed to read config file: %w.", err)
-// 	}
-
-// 	rawData := c.v.AllSettings()
-// 	jsonData, err := json.Marshal(rawData)
-// 	if err != nil {
-// 		return fmt.Errorf("[ConfigVSafe.Load] failed to marshal config to json: %w.", err)
-// 	}
-
-// 	// Create new config instance
-// 	uType := reflect.TypeOf(c.configUnmarshal)
-// 	newConfig := reflect.New(uType).Interface()
-
-// 	if err := json.Unmarshal(jsonData, newConfig); err != nil {
-// 		return fmt.Errorf("[ConfigVSafe.Load] failed to unmarshal config to struct: %w.", err)
-// 	}
-
-// 	c.rw.Lock()
-// 	c.configUnmarshal = c.configUnmarshal
-// 	c.rw.Unlock()
-
-// 	return nil
-// }
-
-// // Watch watches for config changes and updates the config
-// func (c *ConfigVSafe) Watch() {
-// 	c.v.WatchConfig()
-// 	c.v.OnConfigChange(func(in fsnotify.Event) {
-// 		// Get raw config data
-// 		rawData := c.v.AllSettings()
-// 		jsonData, err := json.Marshal(rawData)
-// 		if err != nil {
-// 			log.Printf("[ConfigVSafe.Watch] failed to marshal config: %v", err)
-// 			return
-// 		}
-
-// 		// Create new config instance
-// 		configType := reflect.TypeOf(c.configUnmarshal)
-// 		newConfig := reflect.New(configType).Interface()
-
-// 		// Decode into new instance
-// 		if err := json.Unmarshal(jsonData, newConfig); err != nil {
-// 			log.Printf("[ConfigVSafe.Watch] failed to decode config: %v", err)
-// 			return
-// 		}
-
-// 		// Atomically update the config
-// 		c.rw.Lock()
-// 		c.configUnmarshal = reflect.ValueOf(newConfig).Elem().Interface()
-// 		c.rw.Unlock()
-// 	})
-// }
-
-// // Get returns a deep copy of the current configuration
-// func (c *ConfigVSafe) Get() (any, error) {
-// 	c.rw.RLock()
-// 	defer c.rw.RUnlock()
-
-// 	// Marshal current config
-// 	jsonData, err := json.Marshal(c.configUnmarshal)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("[ConfigVSafe.Get] failed to marshal config: %w", err)
-// 	}
-
-// 	// Create new instance
-// 	configType := reflect.TypeOf(c.configUnmarshal)
-// 	newConfig := reflect.New(configType).Interface()
-
-// 	// Unmarshal into new instance
-// 	if err := json.Unmarshal(jsonData, newConfig); err != nil {
-// 		return nil, fmt.Errorf("[ConfigVSafe.Get] failed to unmarshal config: %w", err)
-// 	}
-
-// 	return newConfig, nil
-// }
-
-// // View executes a function with read-locked access to the config.
-// func (c *ConfigVSafe) View(fn func(any)) {
-// 	c.rw.RLock()
-// 	fn(c.configUnmarshal)
-// 	c.rw.RUnlock()
-// }
-
-// // Viper returns the underlying viper instance.
-// func (c *ConfigVSafe) Viper() *viper.Viper {
-// 	return c.v
-// }
+// apply resolves the references in the settings held by viper, decodes them
+// into a new T, validates it and publishes it. An invalid config, or one
+// vetoed by a ReloadListener, is rejected and the current one is kept. An
+// applied change is recorded in the history with the given reason. The
+// caller must hold c.mu.
+func (c *ConfigV[T]) apply(reason string) (ChangeEvent[T], error) {
+	if err := c.checkUnknownKeys(); err != nil {
+		return ChangeEvent[T]{}, err
+	}
+
+	settings := c.v.AllSettings()
+	hash := c.hashSettings(settings)
+	secrets, err := c.resolveRefs(settings)
+	if err != nil {
+		return ChangeEvent[T]{}, err
+	}
+
+	next, err := c.decode(settings)
+	if err != nil {
+		c.locate(err, secrets)
+		return ChangeEvent[T]{}, fmt.Errorf("failed to unmarshal config to struct: %w", err)
+	}
+
+	if err := validate(next, c.tagName); err != nil {
+		c.locate(err, secrets)
+		return ChangeEvent[T]{}, fmt.Errorf("invalid config, keeping the last valid one: %w", err)
+	}
+
+	prev := c.current.Load()
+	event := ChangeEvent[T]{Old: prev, New: next, Keys: diffKeys(prev, next, c.tagName)}
+	regs, last, err := c.prepare(event)
+	if err != nil {
+		return ChangeEvent[T]{}, err
+	}
+
+	c.current.Store(next)
+	c.secrets.Store(&secrets)
+	entries := c.explain(settings, secrets)
+	c.entries.Store(&entries)
+
+	c.record(reason, hash, event, entries, secrets)
+	c.commit(regs, last, event)
+	return event, nil
+}
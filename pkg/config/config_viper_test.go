@@ -0,0 +1,192 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+type testConfig struct {
+	Env  string
+	Http *testHttp
+}
+
+type testHttp struct {
+	Host string
+	Port int
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestNewConfigV(t *testing.T) {
+	if _, err := NewConfigV[string](); err == nil {
+		t.Error("NewConfigV[string]() error = nil, want non-nil")
+	}
+
+	cv, err := NewConfigV[testConfig]()
+	if err != nil {
+		t.Fatalf("NewConfigV() error = %v", err)
+	}
+	if cv.Get() == nil {
+		t.Error("Get() before Load = nil, want zero value")
+	}
+}
+
+type listNode struct {
+	Name string
+	Next *listNode
+}
+
+func TestNewConfigV_RecursiveType(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "head:\n  name: a\n  next:\n    name: b\n")
+
+	cv, err := NewConfigV[struct{ Head listNode }]()
+	if err != nil {
+		t.Fatalf("NewConfigV() error = %v", err)
+	}
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cv.Get().Head; got.Name != "a" || got.Next == nil || got.Next.Name != "b" {
+		t.Errorf("Get().Head = %+v, want a -> b", got)
+	}
+}
+
+func TestConfigV_LoadAndReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "env: dev\nhttp:\n  host: localhost\n  port: 80\n")
+
+	cv, err := NewConfigV[testConfig]()
+	if err != nil {
+		t.Fatalf("NewConfigV() error = %v", err)
+	}
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	old := cv.Get()
+	if old.Env != "dev" || old.Http.Port != 80 {
+		t.Fatalf("Get() = %+v, want env=dev port=80", old)
+	}
+
+	writeFile(t, file, "env: prod\nhttp:\n  host: example.com\n  port: 8080\n")
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	cur := cv.Get()
+	if cur.Env != "prod" || cur.Http.Port != 8080 {
+		t.Errorf("Get() after Reload = %+v, want env=prod port=8080", cur)
+	}
+	if old.Env != "dev" || old.Http.Host != "localhost" {
+		t.Errorf("old snapshot was modified: %+v", old)
+	}
+}
+
+func TestConfigV_LoadNotFound(t *testing.T) {
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(t.TempDir(), "missing", "yaml"); err == nil {
+		t.Error("Load() error = nil, want not found error")
+	}
+}
+
+// statFailFs fails every Stat.
+type statFailFs struct {
+	afero.Fs
+}
+
+func (statFailFs) Stat(string) (os.FileInfo, error) {
+	return nil, os.ErrPermission
+}
+
+func TestConfigV_LoadStatError(t *testing.T) {
+	cv, _ := NewConfigV[testConfig]()
+	cv.SetFs(statFailFs{afero.NewMemMapFs()})
+
+	err := cv.Load("/conf", "config", "yaml")
+	if err == nil {
+		t.Fatal("Load() error = nil, want the Stat error")
+	}
+	if st := cv.Status(); st.Failures != 1 || st.LastError != err {
+		t.Errorf("Status() = %+v, want the failed Load", st)
+	}
+}
+
+func TestConfigV_ConcurrentGet(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "http:\n  port: 1\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					if conf := cv.Get(); conf.Http == nil || conf.Http.Port == 0 {
+						t.Error("Get() returned a partially decoded snapshot")
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for range 20 {
+		if err := cv.Reload(); err != nil {
+			t.Errorf("Reload() error = %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestConfigV_SetOnChangeDuringReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "env: a\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			cv.SetOnChange(func(ChangeEvent[testConfig]) {})
+		}
+	}()
+	for i := range 20 {
+		writeFile(t, file, "env: "+string(rune('a'+i%2))+"\n")
+		if err := cv.Reload(); err != nil {
+			t.Errorf("Reload() error = %v", err)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,119 @@
+// Package configtest builds config.ConfigV instances for tests from
+// in-memory documents, without touching the disk.
+//
+// The config files live in an afero in-memory filesystem. File changes are
+// simulated by rewriting a file and running the same content check and
+// reload that Watch runs once file events have settled, synchronously, so
+// tests neither sleep nor depend on fsnotify timing.
+package configtest
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chhz0/going/pkg/config"
+	"github.com/spf13/afero"
+)
+
+// Dir is the directory of the in-memory filesystem relative file names are
+// resolved against.
+const Dir = "/config"
+
+// Harness is a ConfigV loaded from a filesystem that tests can change.
+type Harness[T any] struct {
+	*config.ConfigV[T]
+
+	Fs   afero.Fs
+	File string // base config file passed to Load.
+
+	t testing.TB
+}
+
+// New returns a Harness loaded from content in format, e.g. "yaml", "json"
+// or "toml", written to config.<format> in Dir of an in-memory filesystem.
+// setup runs before Load, e.g. to add defaults or a profile. The test fails
+// if the config cannot be loaded.
+func New[T any](t testing.TB, format, content string, setup ...func(*config.ConfigV[T])) *Harness[T] {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	file := filepath.Join(Dir, "config."+format)
+	if err := afero.WriteFile(fs, file, []byte(content), 0644); err != nil {
+		t.Fatalf("configtest: write %s: %v", file, err)
+	}
+	return NewFs(t, fs, file, setup...)
+}
+
+// NewFs returns a Harness loaded from file in fs, together with its
+// includes and profile overlay. A relative file is resolved against Dir.
+// setup runs before Load. The test fails if the config cannot be loaded.
+func NewFs[T any](t testing.TB, fs afero.Fs, file string, setup ...func(*config.ConfigV[T])) *Harness[T] {
+	t.Helper()
+
+	cv, err := config.NewConfigV[T]()
+	if err != nil {
+		t.Fatalf("configtest: %v", err)
+	}
+	cv.SetFs(fs)
+	for _, fn := range setup {
+		fn(cv)
+	}
+
+	h := &Harness[T]{ConfigV: cv, Fs: fs, File: resolve(file), t: t}
+	ext := filepath.Ext(h.File)
+	name := strings.TrimSuffix(filepath.Base(h.File), ext)
+	if err := cv.Load(filepath.Dir(h.File), name, strings.TrimPrefix(ext, ".")); err != nil {
+		t.Fatalf("configtest: %v", err)
+	}
+	return h
+}
+
+// Change replaces the content of the base config file and reloads if the
+// content changed, see ChangeFile.
+func (h *Harness[T]) Change(content string) error {
+	h.t.Helper()
+	return h.ChangeFile(h.File, content)
+}
+
+// ChangeFile writes content to file, relative to Dir unless absolute, and
+// reloads if the content of the config files changed, the way Watch does.
+// It returns the reload error, e.g. for an invalid config, which is then
+// rejected and the last valid one kept. Change handlers run before it
+// returns.
+func (h *Harness[T]) ChangeFile(file, content string) error {
+	h.t.Helper()
+	h.WriteFile(file, content)
+	_, err := h.ReloadIfChanged()
+	return err
+}
+
+// RemoveFile removes file, relative to Dir unless absolute, and reloads
+// the way Watch does.
+func (h *Harness[T]) RemoveFile(file string) error {
+	h.t.Helper()
+	file = resolve(file)
+	if err := h.Fs.Remove(file); err != nil {
+		h.t.Fatalf("configtest: remove %s: %v", file, err)
+	}
+	_, err := h.ReloadIfChanged()
+	return err
+}
+
+// WriteFile writes content to file, relative to Dir unless absolute,
+// without reloading, e.g. to add an include or profile overlay before the
+// file referencing it is changed.
+func (h *Harness[T]) WriteFile(file, content string) {
+	h.t.Helper()
+	file = resolve(file)
+	if err := afero.WriteFile(h.Fs, file, []byte(content), 0644); err != nil {
+		h.t.Fatalf("configtest: write %s: %v", file, err)
+	}
+}
+
+func resolve(file string) string {
+	if filepath.IsAbs(file) {
+		return filepath.Clean(file)
+	}
+	return filepath.Join(Dir, file)
+}
@@ -0,0 +1,126 @@
+package configtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/chhz0/going/pkg/config"
+	"github.com/spf13/afero"
+)
+
+type testConfig struct {
+	Name string `validate:"required"`
+	Http httpConfig
+}
+
+type httpConfig struct {
+	Host string
+	Port int
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		format  string
+		content string
+	}{
+		{"yaml", "name: app\nhttp:\n  port: 80\n"},
+		{"json", `{"name": "app", "http": {"port": 80}}`},
+		{"toml", "name = \"app\"\n[http]\nport = 80\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			h := New[testConfig](t, tt.format, tt.content)
+			if got := h.Get(); got.Name != "app" || got.Http.Port != 80 {
+				t.Errorf("Get() = %+v, want name app and http.port 80", got)
+			}
+		})
+	}
+}
+
+func TestNew_Setup(t *testing.T) {
+	h := New(t, "yaml", "name: app\n", func(cv *config.ConfigV[testConfig]) {
+		cv.SetDefaults(&testConfig{Http: httpConfig{Host: "localhost"}})
+	})
+	if got := h.Get().Http.Host; got != "localhost" {
+		t.Errorf("http.host = %q, want localhost", got)
+	}
+}
+
+func TestNewFs(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/etc/app/config.yaml", []byte("include: db.yaml\nname: app\n"), 0644)
+	afero.WriteFile(fs, "/etc/app/db.yaml", []byte("http:\n  host: db\n"), 0644)
+	afero.WriteFile(fs, "/etc/app/config.prod.yaml", []byte("http:\n  port: 443\n"), 0644)
+
+	h := NewFs(t, fs, "/etc/app/config.yaml", func(cv *config.ConfigV[testConfig]) {
+		cv.SetProfile("prod")
+	})
+	if got := h.Get(); got.Http.Host != "db" || got.Http.Port != 443 {
+		t.Errorf("Get() = %+v, want http.host db and http.port 443", got)
+	}
+	if got := len(h.Files()); got != 3 {
+		t.Errorf("len(Files()) = %d, want 3", got)
+	}
+}
+
+func TestHarness_Change(t *testing.T) {
+	h := New[testConfig](t, "yaml", "name: app\nhttp:\n  port: 80\n")
+
+	var events []config.ChangeEvent[testConfig]
+	h.Subscribe("http", func(e config.ChangeEvent[testConfig]) { events = append(events, e) })
+
+	if err := h.Change("name: app\nhttp:\n  port: 80\n"); err != nil {
+		t.Fatalf("Change() with the same content error = %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("got %d events for unchanged content, want 0", len(events))
+	}
+
+	if err := h.Change("name: app\nhttp:\n  port: 81\n"); err != nil {
+		t.Fatalf("Change() error = %v", err)
+	}
+	if len(events) != 1 || events[0].New.Http.Port != 81 {
+		t.Fatalf("events = %+v, want one event with http.port 81", events)
+	}
+
+	if err := h.Change("http:\n  port: 82\n"); err == nil {
+		t.Error("Change() to an invalid config error = nil, want non-nil")
+	}
+	if got := h.Get().Http.Port; got != 81 {
+		t.Errorf("http.port after invalid change = %d, want 81", got)
+	}
+	if st := h.Status(); st.Failures != 1 || st.Reloads != 2 {
+		t.Errorf("Status() = %+v, want 2 reloads and 1 failure", st)
+	}
+}
+
+func TestHarness_ChangeFile(t *testing.T) {
+	h := New[testConfig](t, "yaml", "name: app\n")
+
+	h.WriteFile("http.yaml", "http:\n  host: included\n")
+	if err := h.Change("include: http.yaml\nname: app\n"); err != nil {
+		t.Fatalf("Change() error = %v", err)
+	}
+	if got := h.Get().Http.Host; got != "included" {
+		t.Fatalf("http.host = %q, want included", got)
+	}
+
+	if err := h.ChangeFile("http.yaml", "http:\n  host: changed\n"); err != nil {
+		t.Fatalf("ChangeFile() error = %v", err)
+	}
+	if got := h.Get().Http.Host; got != "changed" {
+		t.Errorf("http.host = %q, want changed", got)
+	}
+
+	err := h.RemoveFile("http.yaml")
+	if err == nil {
+		t.Fatal("RemoveFile() of an include error = nil, want non-nil")
+	}
+	if !errors.Is(err, afero.ErrFileNotFound) {
+		t.Errorf("RemoveFile() error = %v, want a not found error", err)
+	}
+	if got := h.Get().Http.Host; got != "changed" {
+		t.Errorf("http.host after removal = %q, want changed", got)
+	}
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// EncryptedPrefix marks an encrypted config value. It is followed by the
+	// base64 encoded nonce and AES-256-GCM ciphertext.
+	EncryptedPrefix = "enc:AES256GCM:"
+
+	// DefaultKeyEnv is the environment variable the crypt command reads the
+	// base64 encoded encryption key from by default.
+	DefaultKeyEnv = "CONFIG_ENCRYPTION_KEY"
+
+	keySize = 32
+)
+
+var encryptedPattern = regexp.MustCompile(regexp.QuoteMeta(EncryptedPrefix) + `[A-Za-z0-9+/]+=*`)
+
+// IsEncrypted reports whether value is an encrypted config value.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix)
+}
+
+// Cipher encrypts and decrypts config values with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher returns a Cipher for the 32 byte key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", keySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// GenerateKey returns a new random key, base64 encoded.
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// KeyFromEnv reads a base64 encoded key from the environment variable name.
+func KeyFromEnv(name string) ([]byte, error) {
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable '%s' is not set", name)
+	}
+	return decodeKey(val)
+}
+
+// KeyFromFile reads a base64 encoded key from the file at path.
+func KeyFromFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeKey(string(data))
+}
+
+func decodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not base64 encoded: %w", err)
+	}
+	return key, nil
+}
+
+// Encrypt encrypts plain into an encrypted config value.
+func (c *Cipher) Encrypt(plain string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts an encrypted config value.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", errors.New("value is not encrypted")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return "", errors.New("malformed encrypted value: too short")
+	}
+	plain, err := c.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt value: wrong key or corrupted data")
+	}
+
+	return string(plain), nil
+}
+
+// SetDecryptionKey sets the key used to decrypt encrypted values during
+// Load and every reload. Decrypted values are treated as secrets.
+func (c *ConfigV[T]) SetDecryptionKey(key []byte) error {
+	ci, err := NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("[ConfigV.SetDecryptionKey] %w.", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cipher = ci
+	return nil
+}
+
+// RotateFile re-encrypts every encrypted value in the config file at path
+// from oldCipher to newCipher and returns the number of values rotated. Only
+// the encrypted values are touched, the rest of the file is kept byte for
+// byte, and the file is replaced atomically.
+func RotateFile(path string, oldCipher, newCipher *Cipher) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		n    int
+		errs []error
+	)
+	out := encryptedPattern.ReplaceAllFunc(data, func(value []byte) []byte {
+		plain, err := oldCipher.Decrypt(string(value))
+		if err == nil {
+			var rotated string
+			if rotated, err = newCipher.Encrypt(plain); err == nil {
+				n++
+				return []byte(rotated)
+			}
+		}
+		errs = append(errs, err)
+		return value
+	})
+	if len(errs) > 0 {
+		return 0, fmt.Errorf("failed to rotate '%s': %w", path, errs[0])
+	}
+
+	return n, writeFileAtomic(afero.NewOsFs(), path, out)
+}
+
+// writeFileAtomic writes data to a temporary file next to path in fs and
+// renames it over path, keeping the permissions of the existing file.
+func writeFileAtomic(fs afero.Fs, path string, data []byte) error {
+	perm := os.FileMode(0644)
+	if fi, err := fs.Stat(path); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	tmp, err := afero.TempFile(fs, filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer fs.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := fs.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return fs.Rename(tmp.Name(), path)
+}
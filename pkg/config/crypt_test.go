@@ -0,0 +1,125 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T) (*Cipher, string) {
+	t.Helper()
+
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	key, _ := base64.StdEncoding.DecodeString(encoded)
+	ci, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+	return ci, encoded
+}
+
+func TestCipher(t *testing.T) {
+	ci, _ := newTestCipher(t)
+	other, _ := newTestCipher(t)
+
+	enc, err := ci.Encrypt("s3cret")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsEncrypted(enc) || strings.Contains(enc, "s3cret") {
+		t.Fatalf("Encrypt() = %q, want an encrypted value", enc)
+	}
+
+	if plain, err := ci.Decrypt(enc); err != nil || plain != "s3cret" {
+		t.Errorf("Decrypt() = %q, %v, want s3cret", plain, err)
+	}
+	if _, err := other.Decrypt(enc); err == nil {
+		t.Error("Decrypt() with the wrong key error = nil")
+	}
+	if _, err := NewCipher([]byte("short")); err == nil {
+		t.Error("NewCipher() with a short key error = nil")
+	}
+}
+
+func TestConfigV_Decrypt(t *testing.T) {
+	ci, encoded := newTestCipher(t)
+	enc, _ := ci.Encrypt("s3cret")
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "mysql:\n  user: root\n  password: "+enc+"\n")
+
+	cv, _ := NewConfigV[secretConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err == nil {
+		t.Fatal("Load() without a key error = nil")
+	}
+
+	t.Setenv(DefaultKeyEnv, encoded)
+	key, err := KeyFromEnv(DefaultKeyEnv)
+	if err != nil {
+		t.Fatalf("KeyFromEnv() error = %v", err)
+	}
+	if err := cv.SetDecryptionKey(key); err != nil {
+		t.Fatalf("SetDecryptionKey() error = %v", err)
+	}
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if got := cv.Get().Mysql.Password.Value(); got != "s3cret" {
+		t.Errorf("mysql.password = %q, want s3cret", got)
+	}
+	var buf bytes.Buffer
+	if err := cv.Dump(&buf, "table"); err != nil || strings.Contains(buf.String(), "s3cret") {
+		t.Errorf("Dump() = %q, %v, want the decrypted value redacted", buf.String(), err)
+	}
+}
+
+func TestNewCryptCommand(t *testing.T) {
+	_, oldKey := newTestCipher(t)
+	_, newKey := newTestCipher(t)
+	t.Setenv(DefaultKeyEnv, oldKey)
+	keyFile := filepath.Join(t.TempDir(), "new.key")
+	writeFile(t, keyFile, newKey+"\n")
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := NewCryptCommand()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("crypt %v error = %v", args, err)
+		}
+		return strings.TrimSpace(out.String())
+	}
+
+	enc := run("encrypt", "s3cret")
+	if got := run("decrypt", enc); got != "s3cret" {
+		t.Errorf("decrypt = %q, want s3cret", got)
+	}
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "# database\nmysql:\n  user: root # admin\n  password: " + enc + "\n"
+	writeFile(t, file, content)
+
+	if got := run("rotate", file, "--new-key-file", keyFile); !strings.Contains(got, "rotated 1 values") {
+		t.Errorf("rotate output = %q", got)
+	}
+
+	data, _ := os.ReadFile(file)
+	rotated := string(data)
+	if strings.Contains(rotated, enc) || !strings.HasPrefix(rotated, "# database\nmysql:\n  user: root # admin\n") {
+		t.Errorf("rotated file =\n%s\nwant only the encrypted value replaced", rotated)
+	}
+
+	rotatedValue := strings.TrimSpace(strings.SplitN(rotated, "password: ", 2)[1])
+	if got := run("decrypt", rotatedValue, "--key-file", keyFile); got != "s3cret" {
+		t.Errorf("decrypt with the new key = %q, want s3cret", got)
+	}
+}
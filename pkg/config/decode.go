@@ -0,0 +1,173 @@
+package config
+
+import (
+	"encoding"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// SetTagName sets the struct tag config keys are named by, e.g.
+// "mapstructure", "yaml" or "json". It defaults to the first of these tags
+// used by any field of T, so models written for yaml.v3 or encoding/json work
+// without duplicating their tags, and to "mapstructure" if T uses none.
+//
+// SetTagName must be called before SetDefaults, AddEnv, AddFlags and Load.
+func (c *ConfigV[T]) SetTagName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tagName = name
+	c.applyTagDefaults()
+}
+
+// AddDecodeHook adds a mapstructure decode hook, e.g. to decode strings
+// into a type of the application; see StringHook. Hooks run in the order
+// they were added, before the built-in hooks, which decode strings into:
+//
+//   - time.Duration, e.g. "30s"
+//   - ByteSize, e.g. "10MB"
+//   - url.URL and *url.URL
+//   - net.IPNet and *net.IPNet, e.g. "10.0.0.0/8"
+//   - *regexp.Regexp
+//   - any type implementing encoding.TextUnmarshaler, or a pointer to one,
+//     e.g. net.IP, netip.Addr, netip.Prefix and time.Time
+//   - slices, split at commas
+//
+// Decode hooks must be added before Load. A struct type decoded by a hook
+// is still seen as a section by RegisterFlags, AddEnv and strict mode;
+// implement encoding.TextUnmarshaler instead to have it seen as one value.
+func (c *ConfigV[T]) AddDecodeHook(hook mapstructure.DecodeHookFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks = append(c.hooks, hook)
+}
+
+// StringHook returns a decode hook that decodes strings into V with parse.
+// The hook also decodes into *V.
+func StringHook[V any](parse func(s string) (V, error)) mapstructure.DecodeHookFunc {
+	typ := reflect.TypeFor[V]()
+	return func(f reflect.Type, t reflect.Type, data any) (any, error) {
+		if f.Kind() != reflect.String || (t != typ && t != reflect.PointerTo(typ)) {
+			return data, nil
+		}
+
+		v, err := parse(data.(string))
+		if err != nil {
+			return nil, err
+		}
+		if t.Kind() == reflect.Pointer && typ.Kind() != reflect.Pointer {
+			return &v, nil
+		}
+		return v, nil
+	}
+}
+
+// decodeHooks returns the user hooks followed by the built-in hooks listed
+// at AddDecodeHook.
+func (c *ConfigV[T]) decodeHooks() []mapstructure.DecodeHookFunc {
+	hooks := append([]mapstructure.DecodeHookFunc(nil), c.hooks...)
+	return append(hooks,
+		mapstructure.StringToTimeDurationHookFunc(),
+		StringHook(url.Parse),
+		StringHook(func(s string) (url.URL, error) {
+			u, err := url.Parse(s)
+			if err != nil {
+				return url.URL{}, err
+			}
+			return *u, nil
+		}),
+		StringHook(func(s string) (net.IPNet, error) {
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				return net.IPNet{}, err
+			}
+			return *n, nil
+		}),
+		StringHook(regexp.Compile),
+		textUnmarshalerHookFunc(),
+		stringToSliceHookFunc(","),
+	)
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// textUnmarshaler returns the type implementing encoding.TextUnmarshaler for
+// values of typ, either typ itself or typ's element type when typ is a
+// pointer, or nil.
+func textUnmarshaler(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		return typ
+	}
+	return nil
+}
+
+// textUnmarshalerHookFunc decodes strings into types implementing
+// encoding.TextUnmarshaler and pointers to them.
+func textUnmarshalerHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data any) (any, error) {
+		typ := textUnmarshaler(t)
+		if f.Kind() != reflect.String || typ == nil {
+			return data, nil
+		}
+
+		v := reflect.New(typ)
+		if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(reflect.ValueOf(data).String())); err != nil {
+			return nil, err
+		}
+		if t.Kind() == reflect.Pointer {
+			return v.Interface(), nil
+		}
+		return v.Elem().Interface(), nil
+	}
+}
+
+// decode decodes settings into a new T the same way viper.Unmarshal does.
+// Failures are returned as joined DecodeErrors.
+func (c *ConfigV[T]) decode(settings map[string]any) (*T, error) {
+	next := new(T)
+
+	squash := "squash"
+	if c.tagName == "yaml" {
+		squash = "inline"
+	}
+
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           next,
+		TagName:          c.tagName,
+		SquashTagOption:  squash,
+		WeaklyTypedInput: true,
+		DecodeHook:       mapstructure.ComposeDecodeHookFunc(c.decodeHooks()...),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dec.Decode(settings); err != nil {
+		return nil, decodeErrors(err)
+	}
+
+	return next, nil
+}
+
+// stringToSliceHookFunc splits strings into slices of any element type,
+// e.g. "1,2" into []int{1, 2}.
+func stringToSliceHookFunc(sep string) mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data any) (any, error) {
+		if f.Kind() != reflect.String || t.Kind() != reflect.Slice {
+			return data, nil
+		}
+
+		raw := data.(string)
+		if raw == "" {
+			return []string{}, nil
+		}
+		return strings.Split(raw, sep), nil
+	}
+}
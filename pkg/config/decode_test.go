@@ -0,0 +1,228 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+type YAMLBase struct {
+	Name string `yaml:"app_name"`
+}
+
+type yamlConfig struct {
+	YAMLBase `yaml:",inline"`
+	Mysql    *struct {
+		DB   string `yaml:"db_name"`
+		Pool int    `yaml:"max_pool" default:"4"`
+	} `yaml:"database"`
+	Ignored string `yaml:"-"`
+}
+
+type jsonConfig struct {
+	Http struct {
+		Port int `json:"listen_port,omitempty"`
+	} `json:"server"`
+}
+
+type mixedConfig struct {
+	Name string `yaml:"name" mapstructure:"app_name"`
+}
+
+func TestDetectTagName(t *testing.T) {
+	tests := []struct {
+		typ  reflect.Type
+		want string
+	}{
+		{reflect.TypeFor[testConfig](), "mapstructure"},
+		{reflect.TypeFor[yamlConfig](), "yaml"},
+		{reflect.TypeFor[jsonConfig](), "json"},
+		{reflect.TypeFor[mixedConfig](), "mapstructure"},
+	}
+
+	for _, tt := range tests {
+		if got := detectTagName(tt.typ); got != tt.want {
+			t.Errorf("detectTagName(%s) = %q, want %q", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestConfigV_YAMLTags(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "app_name: demo\ndatabase:\n  db_name: orders\nignored: x\n")
+
+	cv, _ := NewConfigV[yamlConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	got := cv.Get()
+	if got.Name != "demo" || got.Mysql == nil || got.Mysql.DB != "orders" || got.Mysql.Pool != 4 || got.Ignored != "" {
+		t.Errorf("Get() = %+v, want app_name, db_name and the max_pool default", got)
+	}
+	if want := []string{"app_name", "database.db_name", "database.max_pool"}; !reflect.DeepEqual(keys(cv.fields()), want) {
+		t.Errorf("fields() = %v, want %v", keys(cv.fields()), want)
+	}
+}
+
+func TestConfigV_SetTagName(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.json"), `{"server": {"listen_port": 8080}, "name": "yaml", "app_name": "mapstructure"}`)
+
+	cv, _ := NewConfigV[jsonConfig]()
+	if err := cv.Load(dir, "config", "json"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cv.Get().Http.Port; got != 8080 {
+		t.Errorf("http port = %d, want 8080", got)
+	}
+
+	mixed, _ := NewConfigV[mixedConfig]()
+	mixed.SetTagName("yaml")
+	if err := mixed.Load(dir, "config", "json"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := mixed.Get().Name; got != "yaml" {
+		t.Errorf("name with the yaml tag = %q, want yaml", got)
+	}
+}
+
+func keys(fields []field) []string {
+	out := make([]string, len(fields))
+	for i, f := range fields {
+		out[i] = f.key
+	}
+	return out
+}
+
+// semver is an application type decoded by a custom hook.
+type semver struct{ Major, Minor int }
+
+func parseSemver(s string) (semver, error) {
+	var v semver
+	if _, err := fmt.Sscanf(s, "v%d.%d", &v.Major, &v.Minor); err != nil {
+		return semver{}, fmt.Errorf("invalid version '%s'", s)
+	}
+	return v, nil
+}
+
+type hookConfig struct {
+	Timeout   time.Duration
+	MaxBody   ByteSize `validate:"max=1MiB"`
+	Endpoint  *url.URL
+	Homepage  url.URL
+	Bind      net.IP
+	Trusted   net.IPNet
+	Addr      netip.Addr
+	Allowed   netip.Prefix
+	Pattern   *regexp.Regexp
+	StartedAt time.Time
+	Release   semver
+	Previous  *semver
+	Ports     []int
+}
+
+func TestConfigV_DecodeHooks(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), `timeout: 30s
+maxbody: 512KB
+endpoint: https://api.example.com/v1
+homepage: https://example.com
+bind: 10.0.0.1
+trusted: 10.0.0.0/8
+addr: ::1
+allowed: 192.168.0.0/16
+pattern: ^user-[0-9]+$
+startedat: 2024-01-02T03:04:05Z
+release: v1.2
+previous: v1.1
+ports: 80,443
+`)
+
+	cv, _ := NewConfigV[hookConfig]()
+	cv.AddDecodeHook(StringHook(parseSemver))
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	got := cv.Get()
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"timeout", got.Timeout, 30 * time.Second},
+		{"maxbody", got.MaxBody, 512 * KB},
+		{"endpoint", got.Endpoint.String(), "https://api.example.com/v1"},
+		{"homepage", got.Homepage.Host, "example.com"},
+		{"bind", got.Bind.String(), "10.0.0.1"},
+		{"trusted", got.Trusted.String(), "10.0.0.0/8"},
+		{"addr", got.Addr, netip.MustParseAddr("::1")},
+		{"allowed", got.Allowed, netip.MustParsePrefix("192.168.0.0/16")},
+		{"pattern", got.Pattern.String(), "^user-[0-9]+$"},
+		{"startedat", got.StartedAt, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"release", got.Release, semver{1, 2}},
+		{"previous", *got.Previous, semver{1, 1}},
+		{"ports", got.Ports, []int{80, 443}},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	// Text values are single keys, not sections.
+	for _, key := range []string{"endpoint", "homepage", "trusted", "allowed", "pattern"} {
+		if !slices.Contains(keys(cv.fields()), key) {
+			t.Errorf("fields() = %v, want key %q", keys(cv.fields()), key)
+		}
+	}
+}
+
+func TestConfigV_DecodeHookErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		key     string
+	}{
+		{"maxbody: 2MB\n", "maxbody"},
+		{"maxbody: lots\n", "maxbody"},
+		{"bind: 10.0.0\n", "bind"},
+		{"pattern: \"[\"\n", "pattern"},
+		{"release: 1.2\n", "release"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "config.yaml"), tt.content)
+
+			cv, _ := NewConfigV[hookConfig]()
+			cv.AddDecodeHook(StringHook(parseSemver))
+			err := cv.Load(dir, "config", "yaml")
+
+			var (
+				de *DecodeError
+				ve *ValidationError
+			)
+			switch {
+			case errors.As(err, &de):
+				if de.Key != tt.key {
+					t.Errorf("DecodeError key = %q, want %q", de.Key, tt.key)
+				}
+			case errors.As(err, &ve):
+				if ve.Key != tt.key {
+					t.Errorf("ValidationError key = %q, want %q", ve.Key, tt.key)
+				}
+			default:
+				t.Errorf("Load() error = %v, want an error for %s", err, tt.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ChangeEvent describes an applied configuration change.
+type ChangeEvent[T any] struct {
+	Old *T // the previous snapshot.
+	New *T // the snapshot now returned by Get.
+
+	Keys []string // key paths whose value changed, e.g. "mysql.url".
+}
+
+// Changed reports whether the key path prefix, or any key below it, changed.
+// For example Changed("mysql") is true when "mysql.url" changed.
+func (e ChangeEvent[T]) Changed(prefix string) bool {
+	for _, key := range e.Keys {
+		if matchKey(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchKey reports whether key equals prefix or is nested below it.
+// An empty prefix matches every key.
+func matchKey(key, prefix string) bool {
+	return prefix == "" || key == prefix || strings.HasPrefix(key, prefix+".")
+}
+
+// diffKeys returns the key paths of the leaf fields that differ between old
+// and new, in struct field order.
+func diffKeys[T any](old, new *T, tagName string) []string {
+	var keys []string
+
+	oldRoot, newRoot := reflect.ValueOf(old), reflect.ValueOf(new)
+	for _, f := range structFields(reflect.TypeFor[T](), tagName) {
+		ov, ook := fieldValue(oldRoot, f)
+		nv, nok := fieldValue(newRoot, f)
+
+		switch {
+		case !ook && !nok:
+		case ook != nok:
+			keys = append(keys, f.key)
+		case !reflect.DeepEqual(ov.Interface(), nv.Interface()):
+			keys = append(keys, f.key)
+		}
+	}
+
+	return keys
+}
+
+// DiffTarget selects a configuration to compare with Diff.
+type DiffTarget struct {
+	File    string            // config file, e.g. "conf/config.yaml".
+	Profile string            // profile to load, see SetProfile.
+	Env     map[string]string // environment variables set while loading.
+}
+
+func (t DiffTarget) String() string {
+	if t.Profile == "" {
+		return t.File
+	}
+	return t.File + "@" + t.Profile
+}
+
+// ValueDiff is a key whose effective value differs between two
+// configurations. Values are nil for unset sections and redacted for
+// secrets.
+type ValueDiff struct {
+	Key        string `json:"key"`
+	From       any    `json:"from"`
+	To         any    `json:"to"`
+	FromSource string `json:"from_source"` // as in Entry.
+	ToSource   string `json:"to_source"`
+}
+
+// Diff loads the configurations from and to and returns the keys whose
+// effective values differ, in struct field order. Values are compared
+// after decoding, so "60s" and "1m" are equal durations.
+//
+// Both configurations go through the full pipeline of a ConfigV returned by
+// newConfig, which should set it up like the application does, e.g. with
+// the same sources, env prefix, resolvers and decryption key. The
+// environment variables of a target are set for the whole process while it
+// is loaded, so Diff must not run concurrently with code reading them.
+func Diff[T any](newConfig func() (*ConfigV[T], error), from, to DiffTarget) ([]ValueDiff, error) {
+	a, err := loadTarget(newConfig, from)
+	if err != nil {
+		return nil, fmt.Errorf("[Diff] %s: %w", from, err)
+	}
+	b, err := loadTarget(newConfig, to)
+	if err != nil {
+		return nil, fmt.Errorf("[Diff] %s: %w", to, err)
+	}
+
+	aEntries, bEntries := a.Explain(), b.Explain()
+	aRoot, bRoot := reflect.ValueOf(a.Get()), reflect.ValueOf(b.Get())
+
+	fields := a.fields()
+	var diffs []ValueDiff
+	for _, key := range diffKeys(a.Get(), b.Get(), a.tagName) {
+		f := fields[slices.IndexFunc(fields, func(f field) bool { return f.key == key })]
+		d := ValueDiff{
+			Key:        key,
+			From:       displayValue(aRoot, f),
+			To:         displayValue(bRoot, f),
+			FromSource: entrySource(aEntries, key),
+			ToSource:   entrySource(bEntries, key),
+		}
+		if a.IsSecret(key) || b.IsSecret(key) {
+			d.From, d.To = redacted, redacted
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+// loadTarget loads the configuration t with a ConfigV from newConfig.
+func loadTarget[T any](newConfig func() (*ConfigV[T], error), t DiffTarget) (*ConfigV[T], error) {
+	c, err := newConfig()
+	if err != nil {
+		return nil, err
+	}
+	if t.Profile != "" {
+		c.SetProfile(t.Profile)
+	}
+
+	for name, val := range t.Env {
+		old, ok := os.LookupEnv(name)
+		if err := os.Setenv(name, val); err != nil {
+			return nil, err
+		}
+		if ok {
+			defer os.Setenv(name, old)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	ext := filepath.Ext(t.File)
+	name := strings.TrimSuffix(filepath.Base(t.File), ext)
+	if err := c.Load(filepath.Dir(t.File), name, strings.TrimPrefix(ext, ".")); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// displayValue returns the value of the field f of root for display, with
+// values such as durations and byte sizes formatted as strings.
+func displayValue(root reflect.Value, f field) any {
+	v, ok := fieldValue(root, f)
+	if !ok {
+		return nil
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch val := v.Interface().(type) {
+	case fmt.Stringer:
+		return val.String()
+	case encoding.TextMarshaler:
+		if text, err := val.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	if v.CanAddr() {
+		if s, ok := v.Addr().Interface().(fmt.Stringer); ok {
+			return s.String()
+		}
+	}
+	return v.Interface()
+}
+
+// entrySource returns the source of key, or of the first key below it,
+// in the sorted entries.
+func entrySource(entries []Entry, key string) string {
+	for _, e := range entries {
+		if matchKey(e.Key, key) {
+			return e.Source
+		}
+	}
+	return ""
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffKeys(t *testing.T) {
+	old := &testConfig{Env: "dev", Http: &testHttp{Host: "localhost", Port: 80}}
+
+	tests := []struct {
+		name string
+		new  *testConfig
+		want []string
+	}{
+		{"equal", &testConfig{Env: "dev", Http: &testHttp{Host: "localhost", Port: 80}}, nil},
+		{"leaf", &testConfig{Env: "prod", Http: &testHttp{Host: "localhost", Port: 80}}, []string{"env"}},
+		{"nested", &testConfig{Env: "dev", Http: &testHttp{Host: "localhost", Port: 8080}}, []string{"http.port"}},
+		{"nil section", &testConfig{Env: "dev"}, []string{"http.host", "http.port"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffKeys(old, tt.new, "mapstructure"); !slices.Equal(got, tt.want) {
+				t.Errorf("diffKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeEvent_Changed(t *testing.T) {
+	e := ChangeEvent[testConfig]{Keys: []string{"http.port", "env"}}
+
+	for prefix, want := range map[string]bool{
+		"":          true,
+		"http":      true,
+		"http.port": true,
+		"http.host": false,
+		"htt":       false,
+		"mysql":     false,
+	} {
+		if got := e.Changed(prefix); got != want {
+			t.Errorf("Changed(%q) = %v, want %v", prefix, got, want)
+		}
+	}
+}
+
+func TestConfigV_OnChange(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "env: dev\nhttp:\n  port: 80\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var events []ChangeEvent[testConfig]
+	cv.SetOnChange(func(e ChangeEvent[testConfig]) { events = append(events, e) })
+
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("OnChange called %d times for an unchanged file, want 0", len(events))
+	}
+
+	writeFile(t, file, "env: dev\nhttp:\n  port: 8080\n")
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("OnChange called %d times, want 1", len(events))
+	}
+
+	e := events[0]
+	if !slices.Equal(e.Keys, []string{"http.port"}) {
+		t.Errorf("Keys = %v, want [http.port]", e.Keys)
+	}
+	if e.Old.Http.Port != 80 || e.New.Http.Port != 8080 || e.New != cv.Get() {
+		t.Errorf("Old/New = %+v/%+v, want 80/8080 with New as current", e.Old.Http, e.New.Http)
+	}
+}
+
+type diffConfig struct {
+	Env     string
+	Timeout time.Duration
+	Http    struct {
+		Port int
+	}
+	Mysql struct {
+		Password string `secret:"true"`
+	}
+}
+
+func newDiffConfig() (*ConfigV[diffConfig], error) {
+	c, err := NewConfigV[diffConfig]()
+	if err != nil {
+		return nil, err
+	}
+	return c, c.AddEnv("difftest")
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	staging := filepath.Join(dir, "staging.yaml")
+	writeFile(t, staging, "env: staging\ntimeout: 60s\nhttp:\n  port: 80\nmysql:\n  password: s3cret\n")
+	writeFile(t, filepath.Join(dir, "staging.prod.yaml"), "env: prod\n")
+	prod := filepath.Join(dir, "prod.yaml")
+	writeFile(t, prod, "env: prod\ntimeout: 1m\nhttp:\n  port: 8080\nmysql:\n  password: other\n")
+
+	tests := []struct {
+		name     string
+		from, to DiffTarget
+		want     []ValueDiff
+	}{
+		{"files", DiffTarget{File: staging}, DiffTarget{File: prod}, []ValueDiff{
+			{Key: "env", From: "staging", To: "prod", FromSource: "file:" + staging, ToSource: "file:" + prod},
+			{Key: "http.port", From: 80, To: 8080, FromSource: "file:" + staging, ToSource: "file:" + prod},
+			{Key: "mysql.password", From: redacted, To: redacted, FromSource: "file:" + staging, ToSource: "file:" + prod},
+		}},
+		{"profiles", DiffTarget{File: staging}, DiffTarget{File: staging, Profile: "prod"}, []ValueDiff{
+			{Key: "env", From: "staging", To: "prod", FromSource: "file:" + staging, ToSource: "file:" + filepath.Join(dir, "staging.prod.yaml")},
+		}},
+		{"env", DiffTarget{File: staging}, DiffTarget{File: staging, Env: map[string]string{"DIFFTEST_TIMEOUT": "2m"}}, []ValueDiff{
+			{Key: "timeout", From: "1m0s", To: "2m0s", FromSource: "file:" + staging, ToSource: "env:DIFFTEST_TIMEOUT"},
+		}},
+		{"equal", DiffTarget{File: prod}, DiffTarget{File: prod}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff(newDiffConfig, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := os.LookupEnv("DIFFTEST_TIMEOUT"); ok {
+		t.Error("DIFFTEST_TIMEOUT is still set after Diff")
+	}
+	if _, err := Diff(newDiffConfig, DiffTarget{File: staging}, DiffTarget{File: filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Error("Diff() error = nil, want an error for a missing file")
+	}
+}
+
+func TestNewDiffCommand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "env: staging\nhttp:\n  port: 80\nmysql:\n  password: s3cret\n")
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := NewDiffCommand(newDiffConfig)
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("diff error = %v", err)
+		}
+		return out.String()
+	}
+
+	out := run(file, "--to-env", "DIFFTEST_HTTP_PORT=8080,DIFFTEST_MYSQL_PASSWORD=other")
+	for _, want := range []string{"http.port", "80", "8080", "mysql.password", redacted} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output = %q, want %q", out, want)
+		}
+	}
+	if strings.Contains(out, "s3cret") || strings.Contains(out, "other") {
+		t.Errorf("output = %q, want secrets redacted", out)
+	}
+
+	if out := run(file, file); !strings.Contains(out, "no differences") {
+		t.Errorf("output = %q, want no differences", out)
+	}
+}
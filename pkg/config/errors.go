@@ -0,0 +1,244 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// The errors returned by Load and Reload wrap the following types, which
+// can be extracted with errors.As. Several decode or validation errors are
+// joined with errors.Join.
+
+// NotFoundError reports a missing config file, either the base file passed
+// to Load or an included file.
+type NotFoundError struct {
+	File string
+	Err  error
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("config file '%s' not found", e.File)
+}
+
+func (e *NotFoundError) Unwrap() error { return e.Err }
+
+// ParseError reports a config file or source document that is not valid in
+// its format.
+type ParseError struct {
+	File   string // config file or source name.
+	Line   int    // 1-based line of the error, 0 if unknown.
+	Column int    // 1-based column of the error, 0 if unknown.
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: failed to parse: %v", location(e.File, e.Line, e.Column), e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// DecodeError reports a value that cannot be decoded into the type of its
+// field, e.g. "abc" for an int.
+type DecodeError struct {
+	Key    string // key path of the value, e.g. "http.port".
+	Source string // layer the value came from, as reported by Explain.
+	File   string // config file the value was read from, if any.
+	Line   int    // 1-based line of the value, 0 if unknown.
+	Column int    // 1-based column of the value, 0 if unknown.
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return describe(e.Key, e.Source, e.File, e.Line, e.Column, ": "+e.Err.Error())
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// ValidationError reports a value violating a `validate:"..."` rule of its
+// field or the JSON Schema of the config files.
+type ValidationError struct {
+	Key    string // key path of the value, e.g. "http.port".
+	Source string // layer the value came from, as reported by Explain.
+	File   string // config file the value was read from, if any.
+	Line   int    // 1-based line of the value, 0 if unknown.
+	Column int    // 1-based column of the value, 0 if unknown.
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	return describe(e.Key, e.Source, e.File, e.Line, e.Column, " "+e.Err.Error())
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// location formats a position as file:line:col, leaving out unknown parts.
+func location(file string, line, col int) string {
+	switch {
+	case line == 0:
+		return file
+	case col == 0:
+		return fmt.Sprintf("%s:%d", file, line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", file, line, col)
+	}
+}
+
+func describe(key, source, file string, line, col int, msg string) string {
+	s := fmt.Sprintf("key '%s'%s", key, msg)
+	switch {
+	case file != "":
+		return location(file, line, col) + ": " + s
+	case source != "" && source != SourceDefault:
+		return fmt.Sprintf("%s (from %s)", s, source)
+	default:
+		return s
+	}
+}
+
+// yamlLinePattern matches the position in the messages of yaml syntax
+// errors, which are not available otherwise.
+var yamlLinePattern = regexp.MustCompile(`yaml: line (\d+)(?:, column (\d+))?:`)
+
+// newParseError returns a ParseError for err, the error of parsing data,
+// with the position of the error if the parser reports one.
+func newParseError(file string, data []byte, err error) *ParseError {
+	pe := &ParseError{File: file, Err: err}
+
+	var (
+		syntaxErr *json.SyntaxError
+		tomlErr   *toml.DecodeError
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset is just past the offending byte.
+		pe.Line, pe.Column = offsetPosition(data, max(syntaxErr.Offset-1, 0))
+	case errors.As(err, &tomlErr):
+		pe.Line, pe.Column = tomlErr.Position()
+	default:
+		if m := yamlLinePattern.FindStringSubmatch(err.Error()); m != nil {
+			pe.Line, _ = strconv.Atoi(m[1])
+			pe.Column, _ = strconv.Atoi(m[2])
+		}
+	}
+	return pe
+}
+
+// offsetPosition returns the line and column of the byte at offset in data.
+func offsetPosition(data []byte, offset int64) (line, col int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line = 1 + strings.Count(string(before), "\n")
+	col = int(offset) - strings.LastIndexByte(string(before), '\n')
+	return line, col
+}
+
+// decodeNamePattern matches the field path in the messages of
+// mapstructure errors, e.g. 'Http.Port' in "cannot parse 'Http.Port' as int".
+var decodeNamePattern = regexp.MustCompile(`'([^']*)'`)
+
+// decodeErrors splits err, as returned by mapstructure, into one DecodeError
+// per failing field.
+func decodeErrors(err error) error {
+	var errs []error
+	for _, leaf := range leafErrors(err) {
+		de := &DecodeError{Err: leaf}
+		if m := decodeNamePattern.FindStringSubmatch(leaf.Error()); m != nil {
+			de.Key, _, _ = strings.Cut(strings.ToLower(m[1]), "[")
+		}
+		errs = append(errs, de)
+	}
+	return errors.Join(errs...)
+}
+
+// leafErrors flattens the errors joined in err, looking through errors
+// wrapping joined errors, such as the summary mapstructure returns.
+func leafErrors(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		for inner := errors.Unwrap(err); inner != nil; inner = errors.Unwrap(inner) {
+			if _, ok := inner.(interface{ Unwrap() []error }); ok {
+				return leafErrors(inner)
+			}
+		}
+		return []error{err}
+	}
+
+	var leaves []error
+	for _, e := range joined.Unwrap() {
+		leaves = append(leaves, leafErrors(e)...)
+	}
+	return leaves
+}
+
+// locate fills in the source and position of the decode and validation
+// errors in err, and redacts the values they quote if the key is a secret,
+// either resolved as one of secrets or reported by IsSecret. The caller must
+// hold c.mu.
+func (c *ConfigV[T]) locate(err error, secrets map[string]struct{}) {
+	isSecret := func(key string) bool {
+		_, ok := secrets[key]
+		return ok || c.IsSecret(key)
+	}
+
+	for _, leaf := range leafErrors(err) {
+		var (
+			de *DecodeError
+			ve *ValidationError
+			qe *quotedError
+		)
+		switch {
+		case errors.As(leaf, &de) && de.Key != "" && de.Source == "":
+			de.Source = c.source(de.Key)
+			de.File, de.Line, de.Column = c.position(de.Source, de.Key)
+			if isSecret(de.Key) {
+				// The messages of mapstructure quote the value.
+				de.Err = errors.New("cannot decode the secret value into its field")
+			}
+		case errors.As(leaf, &ve) && ve.Key != "" && ve.Source == "":
+			ve.Source = c.source(ve.Key)
+			ve.File, ve.Line, ve.Column = c.position(ve.Source, ve.Key)
+			if isSecret(ve.Key) && errors.As(ve.Err, &qe) {
+				qe.value = redacted
+			}
+		}
+	}
+}
+
+// position returns the file and position of the value of key when source
+// is a config file. The position is only known for YAML and JSON files.
+func (c *ConfigV[T]) position(source, key string) (file string, line, col int) {
+	file, ok := strings.CutPrefix(source, "file:")
+	if !ok {
+		return "", 0, 0
+	}
+
+	data, err := afero.ReadFile(c.fs, file)
+	if err != nil {
+		return file, 0, 0
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return file, 0, 0
+	}
+
+	node := doc.Content[0]
+	for _, name := range strings.Split(key, ".") {
+		if node.Kind != yaml.MappingNode {
+			return file, 0, 0
+		}
+		next := mappingValue(node, name)
+		if next == nil {
+			return file, 0, 0
+		}
+		node = next
+	}
+	return file, node.Line, node.Column
+}
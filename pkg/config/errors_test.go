@@ -0,0 +1,119 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigV_NotFoundError(t *testing.T) {
+	dir := t.TempDir()
+	cv, _ := NewConfigV[testConfig]()
+
+	var nf *NotFoundError
+	err := cv.Load(dir, "config", "yaml")
+	if !errors.As(err, &nf) || nf.File != filepath.Join(dir, "config.yaml") {
+		t.Errorf("Load() of a missing file error = %v, want NotFoundError", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), "include: missing.yaml\n")
+	err = cv.Load(dir, "config", "yaml")
+	if !errors.As(err, &nf) || nf.File != filepath.Join(dir, "missing.yaml") {
+		t.Errorf("Load() with a missing include error = %v, want NotFoundError", err)
+	}
+}
+
+func TestConfigV_ParseError(t *testing.T) {
+	tests := []struct {
+		ext      string
+		content  string
+		line     int
+		column   int
+		position string
+	}{
+		{"yaml", "env: dev\nhttp:\n  port: 80\n bad\n", 3, 0, "config.yaml:3"},
+		{"json", "{\n  \"env\": \"dev\",\n  \"http\": }\n", 3, 11, "config.json:3:11"},
+		{"toml", "env = \"dev\"\n[http\n", 2, 6, "config.toml:2:6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ext, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "config."+tt.ext), tt.content)
+
+			cv, _ := NewConfigV[testConfig]()
+			err := cv.Load(dir, "config", tt.ext)
+
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Load() error = %v, want ParseError", err)
+			}
+			if pe.Line != tt.line || pe.Column != tt.column {
+				t.Errorf("ParseError position = %d:%d, want %d:%d", pe.Line, pe.Column, tt.line, tt.column)
+			}
+			if !strings.Contains(err.Error(), tt.position) {
+				t.Errorf("Load() error = %v, want %q", err, tt.position)
+			}
+		})
+	}
+}
+
+func TestConfigV_DecodeError(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "env: dev\nhttp:\n  host: localhost\n  port: eighty\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	err := cv.Load(dir, "config", "yaml")
+
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("Load() error = %v, want DecodeError", err)
+	}
+	if de.Key != "http.port" || de.File != file || de.Line != 4 || de.Column != 9 {
+		t.Errorf("DecodeError = %+v, want http.port at %s:4:9", de, file)
+	}
+	if want := file + ":4:9: key 'http.port'"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Load() error = %v, want %q", err, want)
+	}
+}
+
+func TestConfigV_ValidationError(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "env: stage\nhttp:\n  port: 70000\nmysql:\n  url: mysql://db\ntimeout: 1s\n")
+
+	cv, _ := NewConfigV[validatedConfig]()
+	err := cv.Load(dir, "config", "yaml")
+
+	var got []*ValidationError
+	for _, leaf := range leafErrors(err) {
+		var ve *ValidationError
+		if errors.As(leaf, &ve) {
+			got = append(got, ve)
+		}
+	}
+	if len(got) != 2 {
+		t.Fatalf("Load() error = %v, want 2 ValidationErrors", err)
+	}
+	if ve := got[0]; ve.Key != "env" || ve.File != file || ve.Line != 1 || ve.Column != 6 {
+		t.Errorf("ValidationError = %+v, want env at %s:1:6", ve, file)
+	}
+	if ve := got[1]; ve.Key != "http.port" || ve.Line != 3 || ve.Column != 9 {
+		t.Errorf("ValidationError = %+v, want http.port at %s:3:9", ve, file)
+	}
+
+	t.Setenv("APP_HTTP_PORT", "70000")
+	writeFile(t, file, "env: dev\nhttp:\n  port: 80\nmysql:\n  url: mysql://db\ntimeout: 1s\n")
+	cv.AddEnv("app")
+	err = cv.Reload()
+
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Source != "env:APP_HTTP_PORT" || ve.File != "" {
+		t.Fatalf("Reload() error = %v, want ValidationError from env:APP_HTTP_PORT", err)
+	}
+	if want := "key 'http.port' value must be at most 65535 (from env:APP_HTTP_PORT)"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Reload() error = %v, want %q", err, want)
+	}
+}
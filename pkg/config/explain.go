@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"gopkg.in/yaml.v3"
+)
+
+// SourceDefault is the source of values that come from defaults.
+const SourceDefault = "default"
+
+// Entry is a key of the effective configuration together with the layer its
+// value came from. Secret values are redacted.
+type Entry struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"` // "default", "file:<path>", "env:<NAME>", "flag:--<name>" or a Source name.
+}
+
+// Explain returns the effective configuration of the last successful load or
+// reload, one entry per leaf key, sorted by key.
+func (c *ConfigV[T]) Explain() []Entry {
+	return slices.Clone(*c.entries.Load())
+}
+
+// Dump writes the effective configuration to w in the given format:
+//
+//	yaml   nested document with the source of each key as a line comment
+//	json   array of entries
+//	table  one row per key with its value and source
+func (c *ConfigV[T]) Dump(w io.Writer, format string) error {
+	entries := c.Explain()
+
+	switch format {
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(entriesNode(entries)); err != nil {
+			return fmt.Errorf("[ConfigV.Dump] failed to encode yaml: %w.", err)
+		}
+		return enc.Close()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			return fmt.Errorf("[ConfigV.Dump] failed to encode json: %w.", err)
+		}
+		return nil
+	case "table":
+		table := uitable.New()
+		table.MaxColWidth = 80
+		table.AddRow("KEY", "VALUE", "SOURCE")
+		for _, e := range entries {
+			table.AddRow(e.Key, fmt.Sprint(e.Value), e.Source)
+		}
+		_, err := fmt.Fprintln(w, table.String())
+		return err
+	default:
+		return fmt.Errorf("[ConfigV.Dump] unsupported format '%s', want yaml, json or table.", format)
+	}
+}
+
+// explain builds the entries for the resolved settings. secrets holds the
+// keys resolved from references. The caller must hold c.mu.
+func (c *ConfigV[T]) explain(settings map[string]any, secrets map[string]struct{}) []Entry {
+	flat := make(map[string]any)
+	flattenSettings(settings, "", flat)
+
+	entries := make([]Entry, 0, len(flat))
+	for _, key := range slices.Sorted(maps.Keys(flat)) {
+		val := flat[key]
+		if _, ok := secrets[key]; ok || c.IsSecret(key) {
+			val = redacted
+		}
+		entries = append(entries, Entry{Key: key, Value: val, Source: c.source(key)})
+	}
+
+	return entries
+}
+
+// source returns the layer the value of key came from, following the
+// precedence documented in layers.go.
+func (c *ConfigV[T]) source(key string) string {
+	for _, fs := range c.flagSets {
+		if f := fs.Lookup(key); f != nil && f.Changed {
+			return "flag:--" + key
+		}
+	}
+
+	if c.envEnabled {
+		name := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if prefix := c.v.GetEnvPrefix(); prefix != "" {
+			name = strings.ToUpper(prefix) + "_" + name
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			return "env:" + name
+		}
+	}
+
+	if source, ok := c.keySources[key]; ok {
+		return source
+	}
+
+	return SourceDefault
+}
+
+// flattenSettings flattens nested settings into dotted keys.
+func flattenSettings(m map[string]any, prefix string, out map[string]any) {
+	for k, v := range m {
+		if nested, ok := v.(map[string]any); ok && len(nested) > 0 {
+			flattenSettings(nested, prefix+k+".", out)
+			continue
+		}
+		out[prefix+k] = v
+	}
+}
+
+// entriesNode builds a nested yaml mapping from sorted entries, annotating
+// every value with its source.
+func entriesNode(entries []Entry) *yaml.Node {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, e := range entries {
+		parent := root
+		parts := strings.Split(e.Key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			parent = childMapping(parent, part)
+		}
+
+		val := &yaml.Node{}
+		if err := val.Encode(e.Value); err != nil {
+			val = &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(e.Value)}
+		}
+		val.LineComment = e.Source
+
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: parts[len(parts)-1]}, val)
+	}
+
+	return root
+}
+
+// childMapping returns the mapping stored under key in parent, creating it
+// if needed.
+func childMapping(parent *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == key {
+			return parent.Content[i+1]
+		}
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+	return child
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+type explainConfig struct {
+	Name  string `default:"app"`
+	Mysql struct {
+		Url      string
+		User     string
+		Password Secret
+	}
+	Http struct {
+		Port int
+	}
+}
+
+func newExplainConfigV(t *testing.T) (*ConfigV[explainConfig], string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "mysql:\n  url: localhost:3306\n  user: file\n  password: s3cret\nhttp:\n  port: 80\n")
+	t.Setenv("EXPLAIN_MYSQL_USER", "env-user")
+
+	cv, _ := NewConfigV[explainConfig]()
+	if err := cv.AddEnv("explain"); err != nil {
+		t.Fatalf("AddEnv() error = %v", err)
+	}
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	if err := cv.BindFlags(fs); err != nil {
+		t.Fatalf("BindFlags() error = %v", err)
+	}
+	if err := fs.Parse([]string{"--http.port=8080"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return cv, file
+}
+
+func TestConfigV_Explain(t *testing.T) {
+	cv, file := newExplainConfigV(t)
+
+	want := map[string]Entry{
+		"name":           {Key: "name", Value: "app", Source: SourceDefault},
+		"mysql.url":      {Key: "mysql.url", Value: "localhost:3306", Source: "file:" + file},
+		"mysql.user":     {Key: "mysql.user", Value: "env-user", Source: "env:EXPLAIN_MYSQL_USER"},
+		"mysql.password": {Key: "mysql.password", Value: redacted, Source: "file:" + file},
+		"http.port":      {Key: "http.port", Value: "8080", Source: "flag:--http.port"},
+	}
+
+	entries := cv.Explain()
+	if len(entries) != len(want) {
+		t.Fatalf("Explain() = %v, want %d entries", entries, len(want))
+	}
+	for _, e := range entries {
+		w := want[e.Key]
+		if e.Source != w.Source || fmtValue(e.Value) != fmtValue(w.Value) {
+			t.Errorf("entry %s = %+v, want %+v", e.Key, e, w)
+		}
+	}
+}
+
+func fmtValue(v any) string {
+	data, _ := json.Marshal(v)
+	return strings.Trim(string(data), `"`)
+}
+
+func TestConfigV_Dump(t *testing.T) {
+	cv, file := newExplainConfigV(t)
+
+	for _, format := range []string{"yaml", "json", "table"} {
+		var buf bytes.Buffer
+		if err := cv.Dump(&buf, format); err != nil {
+			t.Fatalf("Dump(%s) error = %v", format, err)
+		}
+
+		out := buf.String()
+		if strings.Contains(out, "s3cret") {
+			t.Errorf("Dump(%s) leaks the secret:\n%s", format, out)
+		}
+		if !strings.Contains(out, "env:EXPLAIN_MYSQL_USER") || !strings.Contains(out, file) {
+			t.Errorf("Dump(%s) misses sources:\n%s", format, out)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := cv.Dump(&buf, "yaml"); err != nil {
+		t.Fatalf("Dump(yaml) error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "mysql:\n  password: '******' # file:") {
+		t.Errorf("Dump(yaml) =\n%s\nwant nested document with source comments", buf.String())
+	}
+
+	if err := cv.Dump(&buf, "xml"); err == nil {
+		t.Error("Dump(xml) error = nil, want unsupported format")
+	}
+}
+
+func TestNewExplainCommand(t *testing.T) {
+	cv, _ := newExplainConfigV(t)
+
+	cmd := NewExplainCommand(cv)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"-o", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(out.Bytes(), &entries); err != nil {
+		t.Fatalf("output is not json: %v\n%s", err, out.String())
+	}
+	if len(entries) != 5 {
+		t.Errorf("got %d entries, want 5", len(entries))
+	}
+}
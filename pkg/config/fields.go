@@ -0,0 +1,183 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// field describes a leaf field of a config struct together with the dotted
+// key path viper knows it by, e.g. "mysql.url".
+type field struct {
+	key   string
+	index []int
+	typ   reflect.Type
+	tag   reflect.StructTag
+}
+
+// structFields returns the leaf fields of the struct type typ, named after
+// their tagName tags, e.g. "mapstructure" or "yaml".
+// Nested structs and pointers to structs are flattened into dotted keys;
+// fields tagged "-" and unexported fields are skipped, and so is a struct
+// nested in itself, e.g. the Next field of a linked list node.
+func structFields(typ reflect.Type, tagName string) []field {
+	var fields []field
+	walkFields(typ, tagName, "", nil, make(map[reflect.Type]bool), &fields)
+	return fields
+}
+
+// fields returns the leaf fields of T, named after the tag set by SetTagName.
+func (c *ConfigV[T]) fields() []field {
+	return structFields(reflect.TypeFor[T](), c.tagName)
+}
+
+// walkFields appends the leaf fields of typ to out. path holds the structs
+// being walked, to stop at one that is nested in itself.
+func walkFields(typ reflect.Type, tagName, prefix string, index []int, path map[reflect.Type]bool, out *[]field) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if path[typ] {
+		return
+	}
+	path[typ] = true
+	defer delete(path, typ)
+
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, squash, skip := fieldKey(sf, tagName)
+		if skip {
+			continue
+		}
+
+		idx := append(append([]int(nil), index...), i)
+		key := name
+		if squash {
+			key = strings.TrimSuffix(prefix, ".")
+		} else if prefix != "" {
+			key = prefix + name
+		}
+
+		if isNestedStruct(sf.Type) {
+			next := key + "."
+			if key == "" {
+				next = ""
+			}
+			walkFields(sf.Type, tagName, next, idx, path, out)
+			continue
+		}
+
+		*out = append(*out, field{key: key, index: idx, typ: sf.Type, tag: sf.Tag})
+	}
+}
+
+// fieldKey returns the lower-cased key of sf as viper sees it, taken from
+// the tagName tag. Both the "squash" option of mapstructure and the "inline"
+// option of yaml flatten a nested struct into its parent.
+func fieldKey(sf reflect.StructField, tagName string) (name string, squash, skip bool) {
+	name = strings.ToLower(sf.Name)
+
+	tag, ok := sf.Tag.Lookup(tagName)
+	if !ok {
+		return name, sf.Anonymous && isNestedStruct(sf.Type), false
+	}
+
+	parts := strings.Split(tag, ",")
+	if parts[0] == "-" {
+		return "", false, true
+	}
+	if parts[0] != "" {
+		name = strings.ToLower(parts[0])
+	}
+	for _, opt := range parts[1:] {
+		if opt == "squash" || opt == "inline" {
+			squash = true
+		}
+	}
+
+	return name, squash, false
+}
+
+// tagNames are the struct tags config keys can be named by, in the order
+// detectTagName prefers them.
+var tagNames = []string{"mapstructure", "yaml", "json"}
+
+// detectTagName returns the first of tagNames used by a field of the struct
+// type typ or of its nested structs, or "mapstructure" if none is.
+func detectTagName(typ reflect.Type) string {
+	used := make(map[string]bool)
+	usedTags(typ, used, make(map[reflect.Type]bool))
+	for _, name := range tagNames {
+		if used[name] {
+			return name
+		}
+	}
+	return tagNames[0]
+}
+
+func usedTags(typ reflect.Type, used map[string]bool, seen map[reflect.Type]bool) {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] {
+		return
+	}
+	seen[typ] = true
+
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		for _, name := range tagNames {
+			if _, ok := sf.Tag.Lookup(name); ok {
+				used[name] = true
+			}
+		}
+		usedTags(sf.Type, used, seen)
+	}
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// isNestedStruct reports whether typ is decoded as a nested section rather
+// than as a single value.
+func isNestedStruct(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct && !isTextValue(typ)
+}
+
+var (
+	urlType   = reflect.TypeFor[url.URL]()
+	ipNetType = reflect.TypeFor[net.IPNet]()
+)
+
+// isTextValue reports whether values of typ are decoded from a single
+// string by the built-in decode hooks, see AddDecodeHook.
+func isTextValue(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ == urlType || typ == ipNetType || textUnmarshaler(typ) != nil
+}
+
+// fieldValue returns the value of f inside the struct value root and whether
+// it is reachable, i.e. no pointer on the way is nil.
+func fieldValue(root reflect.Value, f field) (reflect.Value, bool) {
+	v := root
+	for _, i := range f.index {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// ProfileKey is the key of the base config file that selects the profile
+	// when neither SetProfile nor SetProfileEnv provide one.
+	ProfileKey = "env"
+
+	// IncludeKey is the key listing the files a config file includes.
+	IncludeKey = "include"
+)
+
+// The config file layer is built from several files:
+//
+//   - the base file passed to Load;
+//   - the files listed under its include key, either a single path or a
+//     list, relative to the including file. Included files are merged first,
+//     so the including file wins, and may include further files;
+//   - the overlay of the active profile, named after the base file with the
+//     profile inserted before the extension, e.g. config.prod.yaml for
+//     config.yaml. The overlay is optional and may use includes too.
+//
+// Every file is migrated to the latest version before it is merged, see
+// Migration. Files are deep-merged: nested sections are merged key by key and
+// any other value is replaced.
+
+// SetProfile selects the profile overlay to load, e.g. "prod" for
+// config.prod.yaml. It takes precedence over SetProfileEnv and ProfileKey.
+func (c *ConfigV[T]) SetProfile(profile string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.profile = profile
+}
+
+// SetProfileEnv selects the profile from the environment variable name,
+// e.g. APP_ENV, when it is set and not empty.
+func (c *ConfigV[T]) SetProfileEnv(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.profileEnv = name
+}
+
+// SetFs sets the filesystem config files are read from, e.g. an
+// afero.MemMapFs in tests. Defaults to the OS filesystem. Watch only sees
+// changes on the OS filesystem; use ReloadIfChanged with other filesystems.
+func (c *ConfigV[T]) SetFs(fs afero.Fs) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fs = fs
+}
+
+// Files returns the config files that contributed to the current
+// configuration, in merge order.
+func (c *ConfigV[T]) Files() []string {
+	return slices.Clone(*c.files.Load())
+}
+
+// fileLayer accumulates merged config files and sources.
+type fileLayer struct {
+	fs         afero.Fs
+	settings   map[string]any
+	sources    map[string]string // leaf key path -> "file:<path>" or Source name.
+	files      []string
+	schema     *Schema     // validates every file when set.
+	migrations []Migration // applied to every file and source.
+	hash       hash.Hash   // hash of the names and contents of the files read.
+	missing    []string    // optional files that do not exist.
+}
+
+// readConfig reads the base config file, its includes, the profile overlay
+// and then every Source added with AddSource, and sets the merged result as
+// the config layer of viper. The caller must hold c.mu.
+func (c *ConfigV[T]) readConfig() error {
+	layer := &fileLayer{
+		fs:         c.fs,
+		settings:   make(map[string]any),
+		sources:    make(map[string]string),
+		schema:     c.schema,
+		migrations: c.migrations,
+		hash:       sha256.New(),
+	}
+
+	if c.configFile != "" {
+		if err := layer.read(c.configFile, nil); err != nil {
+			return err
+		}
+
+		if profile := c.activeProfile(layer.settings); profile != "" {
+			ext := filepath.Ext(c.configFile)
+			overlay := strings.TrimSuffix(c.configFile, ext) + "." + profile + ext
+			if _, err := c.fs.Stat(overlay); err == nil {
+				if err := layer.read(overlay, nil); err != nil {
+					return err
+				}
+			} else {
+				layer.missing = append(layer.missing, overlay)
+			}
+		}
+	}
+
+	for _, src := range c.sources {
+		if err := layer.readSource(src); err != nil {
+			return fmt.Errorf("source '%s': %w", src.Name(), err)
+		}
+	}
+
+	// viper has no way to replace the config layer, so reset it with an
+	// empty document before merging the files in.
+	c.v.SetConfigType("yaml")
+	if err := c.v.ReadConfig(strings.NewReader("")); err != nil {
+		return err
+	}
+	if err := c.v.MergeConfigMap(layer.settings); err != nil {
+		return err
+	}
+
+	c.keySources = layer.sources
+	c.files.Store(&layer.files)
+	c.filesHash = string(layer.hash.Sum(nil))
+	c.missing = layer.missing
+	return nil
+}
+
+// activeProfile returns the profile selected by SetProfile, SetProfileEnv or
+// the ProfileKey of the base file, in that order.
+func (c *ConfigV[T]) activeProfile(base map[string]any) string {
+	if c.profile != "" {
+		return c.profile
+	}
+	if c.profileEnv != "" {
+		if profile := os.Getenv(c.profileEnv); profile != "" {
+			return profile
+		}
+	}
+	if profile, ok := base[ProfileKey].(string); ok {
+		return profile
+	}
+	return ""
+}
+
+// read merges file and, before it, the files it includes into l.
+// stack holds the files being included, to detect cycles.
+func (l *fileLayer) read(file string, stack []string) error {
+	file = filepath.Clean(file)
+	if slices.Contains(stack, file) {
+		return fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), file)
+	}
+
+	data, err := afero.ReadFile(l.fs, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &NotFoundError{File: file, Err: err}
+	}
+	if err != nil {
+		return err
+	}
+	l.hash.Write([]byte(file))
+	l.hash.Write(data)
+
+	settings, err := parseConfig(data, strings.TrimPrefix(filepath.Ext(file), "."))
+	if err != nil {
+		return newParseError(file, data, err)
+	}
+	version, err := migrate(settings, l.migrations)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	if l.schema != nil {
+		ext := filepath.Ext(file)
+		if version < latestVersion(l.migrations) || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			err = validateSettings(file, settings, l.schema)
+		} else {
+			err = validateFile(file, data, l.schema)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	includes, err := includePaths(settings[IncludeKey])
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	delete(settings, IncludeKey)
+
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(file), inc)
+		}
+		if err := l.read(inc, append(stack, file)); err != nil {
+			return err
+		}
+	}
+
+	mergeSettings(l.settings, settings, "", "file:"+file, l.sources)
+	l.files = append(l.files, file)
+	return nil
+}
+
+func includePaths(val any) ([]string, error) {
+	switch val := val.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{val}, nil
+	case []any:
+		paths := make([]string, 0, len(val))
+		for _, p := range val {
+			s, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s entry %v, want a path", IncludeKey, p)
+			}
+			paths = append(paths, s)
+		}
+		return paths, nil
+	default:
+		return nil, fmt.Errorf("invalid %s value %v, want a path or a list of paths", IncludeKey, val)
+	}
+}
+
+// mergeSettings deep-merges src into dst and records source as the source of
+// every leaf key taken from src.
+func mergeSettings(dst, src map[string]any, prefix, source string, sources map[string]string) {
+	for k, v := range src {
+		key := prefix + k
+
+		srcMap, srcIsMap := v.(map[string]any)
+		dstMap, dstIsMap := dst[k].(map[string]any)
+		if srcIsMap && dstIsMap {
+			mergeSettings(dstMap, srcMap, key+".", source, sources)
+			continue
+		}
+
+		if srcIsMap {
+			copied := make(map[string]any, len(srcMap))
+			mergeSettings(copied, srcMap, key+".", source, sources)
+			if len(srcMap) == 0 {
+				sources[key] = source
+			}
+			dst[k] = copied
+			continue
+		}
+
+		dst[k] = v
+		sources[key] = source
+	}
+}
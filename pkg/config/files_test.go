@@ -0,0 +1,144 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type profileConfig struct {
+	Env  string
+	Http struct {
+		Host string
+		Port int
+	}
+	Mysql struct {
+		Url  string
+		User string
+	}
+}
+
+func writeProfileFiles(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "include: conf.d/mysql.yaml\nenv: dev\nhttp:\n  host: localhost\n  port: 80\n")
+	writeFile(t, filepath.Join(dir, "conf.d", "mysql.yaml"), "mysql:\n  url: localhost:3306\n  user: root\nhttp:\n  host: included\n")
+	writeFile(t, filepath.Join(dir, "config.dev.yaml"), "http:\n  port: 8080\n")
+	writeFile(t, filepath.Join(dir, "config.prod.yaml"), "include: [prod-db.yaml]\nhttp:\n  host: example.com\n")
+	writeFile(t, filepath.Join(dir, "prod-db.yaml"), "mysql:\n  url: db.example.com:3306\n")
+	return dir
+}
+
+func TestConfigV_ProfileAndIncludes(t *testing.T) {
+	dir := writeProfileFiles(t)
+
+	tests := []struct {
+		name    string
+		setup   func(cv *ConfigV[profileConfig])
+		want    [3]string // http.host, mysql.url, http.port
+		wantLen int
+	}{
+		{"profile from env key", func(*ConfigV[profileConfig]) {}, [3]string{"localhost", "localhost:3306", "8080"}, 3},
+		{"profile from env var", func(cv *ConfigV[profileConfig]) {
+			t.Setenv("TEST_PROFILE", "prod")
+			cv.SetProfileEnv("TEST_PROFILE")
+		}, [3]string{"example.com", "db.example.com:3306", "80"}, 4},
+		{"explicit profile", func(cv *ConfigV[profileConfig]) {
+			t.Setenv("TEST_PROFILE", "dev")
+			cv.SetProfileEnv("TEST_PROFILE")
+			cv.SetProfile("prod")
+		}, [3]string{"example.com", "db.example.com:3306", "80"}, 4},
+		{"missing overlay", func(cv *ConfigV[profileConfig]) { cv.SetProfile("test") }, [3]string{"localhost", "localhost:3306", "80"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cv, _ := NewConfigV[profileConfig]()
+			tt.setup(cv)
+			if err := cv.Load(dir, "config", "yaml"); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			conf := cv.Get()
+			got := [3]string{conf.Http.Host, conf.Mysql.Url, strconv.Itoa(conf.Http.Port)}
+			if got != tt.want {
+				t.Errorf("host, url, port = %v, want %v", got, tt.want)
+			}
+			if conf.Mysql.User != "root" {
+				t.Errorf("mysql.user = %q, want root from the base include", conf.Mysql.User)
+			}
+			if files := cv.Files(); len(files) != tt.wantLen {
+				t.Errorf("Files() = %v, want %d files", files, tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestConfigV_FileProvenance(t *testing.T) {
+	dir := writeProfileFiles(t)
+
+	cv, _ := NewConfigV[profileConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := map[string]string{
+		"http.host": filepath.Join(dir, "config.yaml"),
+		"http.port": filepath.Join(dir, "config.dev.yaml"),
+		"mysql.url": filepath.Join(dir, "conf.d", "mysql.yaml"),
+	}
+	for _, e := range cv.Explain() {
+		if file, ok := want[e.Key]; ok && e.Source != "file:"+file {
+			t.Errorf("source of %s = %q, want file:%s", e.Key, e.Source, file)
+		}
+		if e.Key == IncludeKey {
+			t.Errorf("include directive leaked into the settings")
+		}
+	}
+}
+
+func TestConfigV_IncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "include: a.yaml\n")
+	writeFile(t, filepath.Join(dir, "a.yaml"), "include: config.yaml\n")
+
+	cv, _ := NewConfigV[profileConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("Load() error = %v, want include cycle", err)
+	}
+}
+
+func TestConfigV_WatchIncludes(t *testing.T) {
+	dir := writeProfileFiles(t)
+
+	cv, _ := NewConfigV[profileConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	changed := make(chan ChangeEvent[profileConfig], 16)
+	cv.Subscribe("mysql", func(e ChangeEvent[profileConfig]) { changed <- e })
+	startWatch(t, cv)
+
+	writeFile(t, filepath.Join(dir, "conf.d", "mysql.yaml"), "mysql:\n  url: changed:3306\n  user: root\n")
+
+	// A non-atomic write may be observed half-way, so wait for the final value.
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-changed:
+			if e.New.Mysql.Url == "changed:3306" {
+				if !slices.Contains(e.Keys, "mysql.url") {
+					t.Errorf("event keys = %v, want mysql.url", e.Keys)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no change notification after editing an included file, mysql.url = %q", cv.Get().Mysql.Url)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// RegisterFlags generates one flag per config key on fs, named after the key
+// path, e.g. --mysql.url and --http.port. The flag type follows the field
+// type and the following struct tags are honored:
+//
+//	default:"..."  default value shown in the help output
+//	desc:"..."     flag usage text
+//	short:"p"      one-letter shorthand
+//	flag:"-"       do not generate a flag for the field
+//
+// Flags already defined on fs are left untouched, and fields of types that
+// have no pflag equivalent are skipped.
+func (c *ConfigV[T]) RegisterFlags(fs *pflag.FlagSet) error {
+	for _, f := range c.fields() {
+		if f.tag.Get("flag") == "-" || fs.Lookup(f.key) != nil {
+			continue
+		}
+
+		if !addFlag(fs, f) {
+			continue
+		}
+
+		def, ok := f.tag.Lookup("default")
+		if !ok {
+			continue
+		}
+
+		flag := fs.Lookup(f.key)
+		var err error
+		if sv, ok := flag.Value.(pflag.SliceValue); ok {
+			err = sv.Replace(strings.Split(def, ","))
+		} else {
+			err = flag.Value.Set(def)
+		}
+		if err != nil {
+			return fmt.Errorf("[ConfigV.RegisterFlags] invalid default '%s' for key '%s': %w.", def, f.key, err)
+		}
+		flag.DefValue = flag.Value.String()
+	}
+
+	return nil
+}
+
+// BindFlags generates the config flags on fs with RegisterFlags and adds fs
+// as the flag layer with AddFlags.
+func (c *ConfigV[T]) BindFlags(fs *pflag.FlagSet) error {
+	if err := c.RegisterFlags(fs); err != nil {
+		return err
+	}
+	return c.AddFlags(fs)
+}
+
+// BindCommand binds the config flags to the local flags of cmd.
+func (c *ConfigV[T]) BindCommand(cmd *cobra.Command) error {
+	return c.BindFlags(cmd.Flags())
+}
+
+// addFlag defines a zero-valued flag for f and reports whether the field type
+// is supported.
+func addFlag(fs *pflag.FlagSet, f field) bool {
+	name, short, usage := f.key, f.tag.Get("short"), f.tag.Get("desc")
+
+	typ := f.typ
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == durationType:
+		fs.DurationP(name, short, 0, usage)
+		return true
+	case isTextValue(typ):
+		// Parsed by the decode hooks, see AddDecodeHook.
+		fs.StringP(name, short, "", usage)
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		fs.StringP(name, short, "", usage)
+	case reflect.Bool:
+		fs.BoolP(name, short, false, usage)
+	case reflect.Int:
+		fs.IntP(name, short, 0, usage)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fs.Int64P(name, short, 0, usage)
+	case reflect.Uint:
+		fs.UintP(name, short, 0, usage)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fs.Uint64P(name, short, 0, usage)
+	case reflect.Float32, reflect.Float64:
+		fs.Float64P(name, short, 0, usage)
+	case reflect.Slice:
+		switch typ.Elem().Kind() {
+		case reflect.String:
+			fs.StringSliceP(name, short, nil, usage)
+		case reflect.Int:
+			fs.IntSliceP(name, short, nil, usage)
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,43 @@
+package flags
+
+import (
+	"context"
+	"maps"
+)
+
+// Well-known attributes.
+const (
+	AttributeUser   = "user"
+	AttributeTenant = "tenant"
+)
+
+type attributesKey struct{}
+
+// WithAttribute returns a copy of ctx carrying the attribute name with
+// value, which rules and rollouts evaluate.
+func WithAttribute(ctx context.Context, name, value string) context.Context {
+	attrs := maps.Clone(attributesFrom(ctx))
+	if attrs == nil {
+		attrs = make(map[string]string, 1)
+	}
+	attrs[name] = value
+	return context.WithValue(ctx, attributesKey{}, attrs)
+}
+
+// WithUser returns a copy of ctx carrying the user ID. Percentage rollouts
+// are bucketed by it unless Flag.Bucket names another attribute.
+func WithUser(ctx context.Context, id string) context.Context {
+	return WithAttribute(ctx, AttributeUser, id)
+}
+
+// WithTenant returns a copy of ctx carrying the tenant ID.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return WithAttribute(ctx, AttributeTenant, id)
+}
+
+// attributesFrom returns the attributes of ctx. The map must not be
+// modified.
+func attributesFrom(ctx context.Context) map[string]string {
+	attrs, _ := ctx.Value(attributesKey{}).(map[string]string)
+	return attrs
+}
@@ -0,0 +1,204 @@
+// Package flags evaluates feature flags kept in the config file of a
+// config.ConfigV, so that they hot-reload with it, e.g. through Watch.
+//
+// Flags are declared as a map field of the config struct:
+//
+//	type Config struct {
+//		Features map[string]flags.Flag
+//	}
+//
+// and configured per flag name:
+//
+//	features:
+//	  new-checkout:
+//	    enabled: true
+//	  beta-search:
+//	    enabled: true
+//	    rollout: 25          # percent of users
+//	    rules:
+//	      - attribute: tenant
+//	        in: [acme, globex]
+//
+// Flags are evaluated against the attributes of a context.Context, see
+// WithUser, WithTenant and WithAttribute. Evaluation reads an immutable
+// snapshot behind an atomic pointer and takes no locks, and the result for
+// the same flag and attributes is always the same.
+package flags
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/chhz0/going/pkg/config"
+)
+
+// Flag is the configuration of a feature flag. A flag is on when it is
+// enabled, every rule matches and the context falls into the rollout.
+type Flag struct {
+	// Enabled switches the flag on. A disabled flag is off for everyone.
+	Enabled bool
+
+	// Rollout is the percentage, from 0 to 100, of the values of the Bucket
+	// attribute the flag is on for. Unset means 100.
+	Rollout *float64
+
+	// Bucket is the attribute whose value decides whether a context falls
+	// into the rollout, "user" if empty. Contexts without it are not in a
+	// partial rollout.
+	Bucket string
+
+	// Rules restrict the flag to contexts with matching attributes.
+	Rules []Rule
+}
+
+// Rule matches contexts by the value of an attribute. A rule matches when
+// the attribute is set, is one of In, if In is not empty, and is not one of
+// Except.
+type Rule struct {
+	Attribute string
+	In        []string
+	Except    []string
+}
+
+// Set evaluates the flags of a ConfigV. It is safe for concurrent use.
+type Set struct {
+	mu     sync.Mutex // serializes updates.
+	flags  atomic.Pointer[map[string]*flag]
+	remove func()
+}
+
+// flag is a Flag prepared for evaluation.
+type flag struct {
+	enabled bool
+	rollout uint32 // in hundredths of a percent, 0 to 10000.
+	bucket  string
+	rules   []rule
+}
+
+type rule struct {
+	attribute string
+	in        map[string]struct{}
+	except    map[string]struct{}
+}
+
+// New returns a Set evaluating the flags get returns from the config of cv.
+// The flags are updated whenever a load, reload or rollback of cv changes
+// any key below prefix, e.g. "features". Close stops the updates.
+func New[T any](cv *config.ConfigV[T], prefix string, get func(cfg *T) map[string]Flag) *Set {
+	s := &Set{}
+	s.remove = cv.AddReloadListener(config.ReloadFuncs[T]{
+		CommitFunc: func(e config.ChangeEvent[T]) {
+			if e.Changed(prefix) {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+				s.store(get(e.New))
+			}
+		},
+	})
+
+	// The listener is added first, so that no change is missed: a change
+	// made current before is returned by Get, and one made current after
+	// is committed to the listener, even if it was being applied when the
+	// listener was added. The commit waits for s.mu, so it stores the
+	// flags after the ones read here.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(get(cv.Get()))
+	return s
+}
+
+// Close stops updating the flags from the ConfigV. The flags last loaded
+// can still be evaluated.
+func (s *Set) Close() {
+	s.remove()
+}
+
+// store prepares flags for evaluation and makes them current. The caller
+// must hold s.mu.
+func (s *Set) store(flags map[string]Flag) {
+	compiled := make(map[string]*flag, len(flags))
+	for name, f := range flags {
+		cf := &flag{enabled: f.Enabled, rollout: 10000, bucket: f.Bucket}
+		if f.Rollout != nil {
+			cf.rollout = uint32(min(max(*f.Rollout, 0), 100) * 100)
+		}
+		if cf.bucket == "" {
+			cf.bucket = AttributeUser
+		}
+		for _, r := range f.Rules {
+			cf.rules = append(cf.rules, rule{attribute: r.Attribute, in: toSet(r.In), except: toSet(r.Except)})
+		}
+		compiled[name] = cf
+	}
+	s.flags.Store(&compiled)
+}
+
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+// Enabled reports whether the flag name is on for the attributes of ctx.
+// Unknown flags are off.
+func (s *Set) Enabled(ctx context.Context, name string) bool {
+	f, ok := (*s.flags.Load())[name]
+	if !ok || !f.enabled {
+		return false
+	}
+
+	attrs := attributesFrom(ctx)
+	for _, r := range f.rules {
+		if !r.matches(attrs) {
+			return false
+		}
+	}
+
+	if f.rollout >= 10000 {
+		return true
+	}
+	value, ok := attrs[f.bucket]
+	if !ok {
+		return false
+	}
+	return bucketOf(name, value) < f.rollout
+}
+
+// Names returns the names of the flags, sorted.
+func (s *Set) Names() []string {
+	flags := *s.flags.Load()
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (r rule) matches(attrs map[string]string) bool {
+	value, ok := attrs[r.attribute]
+	if !ok {
+		return false
+	}
+	if _, ok := r.in[value]; len(r.in) > 0 && !ok {
+		return false
+	}
+	_, excluded := r.except[value]
+	return !excluded
+}
+
+// bucketOf maps the value of the bucket attribute to a bucket from 0 to
+// 9999. The flag name is part of the hash, so that the same users are not
+// always the first to get every flag.
+func bucketOf(name, value string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(value))
+	return h.Sum32() % 10000
+}
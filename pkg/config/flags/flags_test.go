@@ -0,0 +1,173 @@
+package flags
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/chhz0/going/pkg/config/configtest"
+)
+
+type testConfig struct {
+	Features map[string]Flag
+}
+
+func features(cfg *testConfig) map[string]Flag { return cfg.Features }
+
+const testFlags = `features:
+  on:
+    enabled: true
+  off:
+    enabled: false
+  half:
+    enabled: true
+    rollout: 50
+  none:
+    enabled: true
+    rollout: 0
+  by-tenant:
+    enabled: true
+    rollout: 50
+    bucket: tenant
+  tenants:
+    enabled: true
+    rules:
+      - attribute: tenant
+        in: [acme, globex]
+        except: [globex]
+  beta:
+    enabled: true
+    rollout: 50
+    rules:
+      - attribute: tenant
+        in: [acme]
+`
+
+func TestSet_Enabled(t *testing.T) {
+	h := configtest.New[testConfig](t, "yaml", testFlags)
+	s := New(h.ConfigV, "features", features)
+	defer s.Close()
+
+	ctx := context.Background()
+	acme := WithTenant(ctx, "acme")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		flag string
+		want bool
+	}{
+		{"enabled", ctx, "on", true},
+		{"disabled", ctx, "off", false},
+		{"unknown", ctx, "missing", false},
+		{"rollout without user", ctx, "half", false},
+		{"rollout 0", WithUser(ctx, "u1"), "none", false},
+		{"rule matches", acme, "tenants", true},
+		{"rule excludes", WithTenant(ctx, "globex"), "tenants", false},
+		{"rule not in", WithTenant(ctx, "initech"), "tenants", false},
+		{"rule without attribute", ctx, "tenants", false},
+		{"rule and rollout", WithUser(acme, "u1"), "beta", bucketOf("beta", "u1") < 5000},
+		{"rule fails before rollout", WithUser(ctx, "u1"), "beta", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Enabled(tt.ctx, tt.flag); got != tt.want {
+				t.Errorf("Enabled(%s) = %v, want %v", tt.flag, got, tt.want)
+			}
+		})
+	}
+
+	if got, want := s.Names(), []string{"beta", "by-tenant", "half", "none", "off", "on", "tenants"}; !slices.Equal(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+}
+
+func TestSet_Rollout(t *testing.T) {
+	h := configtest.New[testConfig](t, "yaml", testFlags)
+	s := New(h.ConfigV, "features", features)
+
+	on := 0
+	for i := range 10000 {
+		ctx := WithUser(context.Background(), "user-"+strconv.Itoa(i))
+		got := s.Enabled(ctx, "half")
+		if got != s.Enabled(ctx, "half") {
+			t.Fatalf("Enabled() for user-%d is not deterministic", i)
+		}
+		if got {
+			on++
+		}
+	}
+	if on < 4800 || on > 5200 {
+		t.Errorf("rollout 50 enabled %d of 10000 users, want about 5000", on)
+	}
+
+	// The bucket attribute decides, not the user.
+	ctx := WithTenant(context.Background(), "acme")
+	want := s.Enabled(ctx, "by-tenant")
+	for i := range 100 {
+		if got := s.Enabled(WithUser(ctx, strconv.Itoa(i)), "by-tenant"); got != want {
+			t.Fatalf("Enabled(by-tenant) for the same tenant = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSet_Reload(t *testing.T) {
+	h := configtest.New[testConfig](t, "yaml", "features:\n  new-ui:\n    enabled: false\n")
+	s := New(h.ConfigV, "features", features)
+	ctx := context.Background()
+
+	if s.Enabled(ctx, "new-ui") {
+		t.Fatal("Enabled(new-ui) = true before the change")
+	}
+	if err := h.Change("features:\n  new-ui:\n    enabled: true\n"); err != nil {
+		t.Fatalf("Change() error = %v", err)
+	}
+	if !s.Enabled(ctx, "new-ui") {
+		t.Error("Enabled(new-ui) = false after the change")
+	}
+
+	s.Close()
+	if err := h.Change("features:\n  new-ui:\n    enabled: false\n"); err != nil {
+		t.Fatalf("Change() error = %v", err)
+	}
+	if !s.Enabled(ctx, "new-ui") {
+		t.Error("Enabled(new-ui) changed after Close")
+	}
+}
+
+func TestSet_Concurrent(t *testing.T) {
+	h := configtest.New[testConfig](t, "yaml", testFlags)
+	s := New(h.ConfigV, "features", features)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := WithUser(context.Background(), strconv.Itoa(i))
+			for range 1000 {
+				s.Enabled(ctx, "half")
+			}
+		}()
+	}
+	for _, enabled := range []string{"true", "false", "true"} {
+		if err := h.Change("features:\n  half:\n    enabled: " + enabled + "\n"); err != nil {
+			t.Fatalf("Change() error = %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func BenchmarkSet_Enabled(b *testing.B) {
+	h := configtest.New[testConfig](b, "yaml", testFlags)
+	s := New(h.ConfigV, "features", features)
+	ctx := WithUser(WithTenant(context.Background(), "acme"), "u1")
+
+	b.ReportAllocs()
+	for b.Loop() {
+		s.Enabled(ctx, "beta")
+	}
+}
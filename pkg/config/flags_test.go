@@ -0,0 +1,67 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type flagConfig struct {
+	Debug   bool          `short:"d" desc:"enable debug mode"`
+	Timeout time.Duration `default:"5s"`
+	Tags    []string      `default:"a,b"`
+	Secret  string        `flag:"-"`
+	Http    struct {
+		Host string `default:"localhost" desc:"listen host"`
+		Port int    `default:"80" desc:"listen port"`
+	}
+	Extra map[string]string
+}
+
+func TestConfigV_BindCommand(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "http:\n  host: file-host\n  port: 8000\n")
+
+	cv, _ := NewConfigV[flagConfig]()
+	cmd := &cobra.Command{Use: "test", RunE: func(*cobra.Command, []string) error { return nil }}
+	if err := cv.BindCommand(cmd); err != nil {
+		t.Fatalf("BindCommand() error = %v", err)
+	}
+
+	fs := cmd.Flags()
+	for _, name := range []string{"debug", "timeout", "tags", "http.host", "http.port"} {
+		if fs.Lookup(name) == nil {
+			t.Errorf("flag --%s not registered", name)
+		}
+	}
+	for _, name := range []string{"secret", "extra"} {
+		if fs.Lookup(name) != nil {
+			t.Errorf("flag --%s registered, want skipped", name)
+		}
+	}
+	if f := fs.Lookup("http.port"); f.DefValue != "80" || f.Usage != "listen port" {
+		t.Errorf("--http.port default/usage = %q/%q, want 80/listen port", f.DefValue, f.Usage)
+	}
+	if f := fs.ShorthandLookup("d"); f == nil || f.Name != "debug" {
+		t.Errorf("shorthand -d = %v, want --debug", f)
+	}
+
+	cmd.SetArgs([]string{"-d", "--http.port=9000"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	conf := cv.Get()
+	if !conf.Debug || conf.Timeout != 5*time.Second || !slices.Equal(conf.Tags, []string{"a", "b"}) {
+		t.Errorf("Get() = %+v, want debug, 5s timeout and tags [a b]", conf)
+	}
+	if conf.Http.Host != "file-host" || conf.Http.Port != 9000 {
+		t.Errorf("http = %+v, want host from file and port from flag", conf.Http)
+	}
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/chhz0/going/pkg/logger/zlog"
+)
+
+const defaultHistorySize = 10
+
+// Snapshot is an applied configuration kept in the history of a ConfigV.
+type Snapshot[T any] struct {
+	Version uint64    // increases with every applied change.
+	Time    time.Time // when the snapshot was applied.
+	Reason  string    // "load", "reload", "set" or "rollback:<version>".
+	Sources []string  // files and sources that contributed.
+	Hash    string    // hex SHA-256 of the settings as read, without secrets.
+	Config  *T
+
+	entries []Entry
+	secrets map[string]struct{}
+}
+
+// KeyChange is a changed key and the layer its new value came from.
+type KeyChange struct {
+	Key    string `json:"key"`
+	Source string `json:"source"` // as in Entry, or "removed".
+}
+
+// AuditRecord describes an applied configuration change.
+type AuditRecord struct {
+	Version uint64      `json:"version"`
+	Time    time.Time   `json:"time"`
+	Reason  string      `json:"reason"`
+	Hash    string      `json:"hash"`
+	Changes []KeyChange `json:"changes"`
+}
+
+// history is the bounded list of applied snapshots of a ConfigV.
+type history[T any] struct {
+	mu        sync.Mutex
+	size      int
+	version   uint64
+	snapshots []Snapshot[T]
+	onAudit   func(AuditRecord)
+}
+
+// SetHistorySize sets how many snapshots are kept, 10 by default.
+// A size of zero disables the history and thereby Rollback.
+func (c *ConfigV[T]) SetHistorySize(n int) {
+	h := &c.history
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.size = max(n, 0)
+	if len(h.snapshots) > h.size {
+		h.snapshots = slices.Clone(h.snapshots[len(h.snapshots)-h.size:])
+	}
+}
+
+// SetAuditHandler sets the function receiving an audit record for every
+// applied change, e.g. AuditLogger. It is called synchronously, with the
+// same restrictions as the SetOnChange callback.
+func (c *ConfigV[T]) SetAuditHandler(fn func(AuditRecord)) {
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+	c.history.onAudit = fn
+}
+
+// AuditLogger returns an audit handler writing records to l.
+func AuditLogger(l zlog.Logger) func(AuditRecord) {
+	return func(r AuditRecord) {
+		l.Infow("config applied",
+			"version", r.Version,
+			"reason", r.Reason,
+			"hash", r.Hash,
+			"changes", r.Changes,
+		)
+	}
+}
+
+// History returns the kept snapshots, oldest first.
+func (c *ConfigV[T]) History() []Snapshot[T] {
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+	return slices.Clone(c.history.snapshots)
+}
+
+// Rollback makes the snapshot with the given version current again and
+// notifies the change handlers, unless a ReloadListener vetoes it. The
+// rollback is recorded as a new snapshot. It lasts until the next reload,
+// e.g. one triggered by Watch.
+func (c *ConfigV[T]) Rollback(version uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.history.mu.Lock()
+	i := slices.IndexFunc(c.history.snapshots, func(s Snapshot[T]) bool { return s.Version == version })
+	var snap Snapshot[T]
+	if i >= 0 {
+		snap = c.history.snapshots[i]
+	}
+	c.history.mu.Unlock()
+	if i < 0 {
+		return fmt.Errorf("[ConfigV.Rollback] version %d is not in the history.", version)
+	}
+
+	prev := c.current.Load()
+	event := ChangeEvent[T]{Old: prev, New: snap.Config, Keys: diffKeys(prev, snap.Config, c.tagName)}
+	regs, last, err := c.prepare(event)
+	if err != nil {
+		return fmt.Errorf("[ConfigV.Rollback] %w", err)
+	}
+
+	c.current.Store(snap.Config)
+	c.secrets.Store(&snap.secrets)
+	c.entries.Store(&snap.entries)
+
+	c.record(fmt.Sprintf("rollback:%d", version), snap.Hash, event, snap.entries, snap.secrets)
+	c.commit(regs, last, event)
+	if len(event.Keys) > 0 {
+		c.notify(event)
+	}
+
+	return nil
+}
+
+// record adds a snapshot for an applied change to the history and emits its
+// audit record. Changes that touched no key are not recorded. The caller
+// must hold c.mu.
+func (c *ConfigV[T]) record(reason, hash string, e ChangeEvent[T], entries []Entry, secrets map[string]struct{}) {
+	if len(e.Keys) == 0 {
+		return
+	}
+
+	h := &c.history
+	h.mu.Lock()
+	h.version++
+	snap := Snapshot[T]{
+		Version: h.version,
+		Time:    time.Now(),
+		Reason:  reason,
+		Sources: append(c.Files(), sourceNames(c.sources)...),
+		Hash:    hash,
+		Config:  e.New,
+		entries: entries,
+		secrets: secrets,
+	}
+	if h.size > 0 {
+		if len(h.snapshots) >= h.size {
+			h.snapshots = slices.Delete(h.snapshots, 0, len(h.snapshots)-h.size+1)
+		}
+		h.snapshots = append(h.snapshots, snap)
+	}
+	onAudit := h.onAudit
+	h.mu.Unlock()
+
+	if onAudit == nil {
+		return
+	}
+
+	changes := make([]KeyChange, 0, len(e.Keys))
+	for _, key := range e.Keys {
+		source := "removed"
+		if i := slices.IndexFunc(entries, func(en Entry) bool { return en.Key == key }); i >= 0 {
+			source = entries[i].Source
+		}
+		changes = append(changes, KeyChange{Key: key, Source: source})
+	}
+	onAudit(AuditRecord{
+		Version: snap.Version,
+		Time:    snap.Time,
+		Reason:  reason,
+		Hash:    hash,
+		Changes: changes,
+	})
+}
+
+// hashSettings returns the hex SHA-256 of the JSON encoding of settings.
+// Since the hash ends up in audit records, it must be taken before
+// references are resolved and values decrypted, and the values of fields
+// tagged as secrets are left out.
+func (c *ConfigV[T]) hashSettings(settings map[string]any) string {
+	flat := make(map[string]any)
+	flattenSettings(settings, "", flat)
+	for _, f := range c.fields() {
+		if f.tag.Get("secret") != "true" && f.typ != secretType {
+			continue
+		}
+		for key := range flat {
+			if matchKey(key, f.key) {
+				flat[key] = redacted
+			}
+		}
+	}
+
+	data, err := json.Marshal(flat)
+	if err != nil {
+		data = fmt.Append(nil, flat)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sourceNames(sources []Source) []string {
+	names := make([]string, 0, len(sources))
+	for _, src := range sources {
+		names = append(names, src.Name())
+	}
+	return names
+}
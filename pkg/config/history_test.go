@@ -0,0 +1,117 @@
+package config
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chhz0/going/pkg/logger/zlog"
+)
+
+func TestConfigV_HistoryHashHidesSecrets(t *testing.T) {
+	type secretConfig struct {
+		Env      string
+		Password string `secret:"true"`
+		Token    string
+	}
+
+	hash := func(content string) string {
+		t.Helper()
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "config.yaml"), content)
+		cv, _ := NewConfigV[secretConfig]()
+		if err := cv.Load(dir, "config", "yaml"); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		return cv.History()[0].Hash
+	}
+
+	t.Setenv("HASH_TEST_TOKEN", "one")
+	base := hash("env: dev\npassword: one\ntoken: ${env:HASH_TEST_TOKEN}\n")
+	if got := hash("env: dev\npassword: two\ntoken: ${env:HASH_TEST_TOKEN}\n"); got != base {
+		t.Error("hash changed with the value of a secret field")
+	}
+	t.Setenv("HASH_TEST_TOKEN", "two")
+	if got := hash("env: dev\npassword: one\ntoken: ${env:HASH_TEST_TOKEN}\n"); got != base {
+		t.Error("hash changed with the resolved value of a reference")
+	}
+	if got := hash("env: prod\npassword: one\ntoken: ${env:HASH_TEST_TOKEN}\n"); got == base {
+		t.Error("hash did not change with a plain value")
+	}
+}
+
+func TestConfigV_HistoryAndRollback(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "env: v1\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	cv.SetHistorySize(2)
+
+	var records []AuditRecord
+	cv.SetAuditHandler(func(r AuditRecord) { records = append(records, r) })
+
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	for _, env := range []string{"v1", "v2", "v3"} {
+		writeFile(t, file, "env: "+env+"\n")
+		if err := cv.Reload(); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+	}
+
+	history := cv.History()
+	if len(history) != 2 {
+		t.Fatalf("History() has %d snapshots, want 2", len(history))
+	}
+	v2, v3 := history[0], history[1]
+	if v2.Config.Env != "v2" || v3.Config.Env != "v3" || v2.Version >= v3.Version {
+		t.Errorf("History() = %s@%d, %s@%d, want v2 then v3", v2.Config.Env, v2.Version, v3.Config.Env, v3.Version)
+	}
+	if v2.Reason != "reload" || v2.Hash == "" || v2.Hash == v3.Hash || !slices.Contains(v2.Sources, file) {
+		t.Errorf("snapshot = %+v, want reason, hash and sources", v2)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("got %d audit records, want 3 (load, v2, v3)", len(records))
+	}
+	if want := []KeyChange{{Key: "env", Source: "file:" + file}}; !slices.Equal(records[2].Changes, want) {
+		t.Errorf("audit changes = %v, want %v", records[2].Changes, want)
+	}
+
+	var event ChangeEvent[testConfig]
+	cv.SetOnChange(func(e ChangeEvent[testConfig]) { event = e })
+	if err := cv.Rollback(v2.Version); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if cv.Get() != v2.Config || event.New != v2.Config || event.Old.Env != "v3" {
+		t.Errorf("after Rollback Get() = %+v, event = %+v", cv.Get(), event)
+	}
+
+	last := records[len(records)-1]
+	if last.Reason != "rollback:"+strconv.FormatUint(v2.Version, 10) || last.Hash != v2.Hash {
+		t.Errorf("rollback audit record = %+v", last)
+	}
+
+	if err := cv.Rollback(1); err == nil {
+		t.Error("Rollback() to a dropped version error = nil")
+	}
+}
+
+func TestAuditLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := AuditLogger(zlog.New(&buf, zlog.InfoLevel, zlog.JSONEncoder))
+
+	handler(AuditRecord{Version: 3, Reason: "reload", Hash: "abc", Changes: []KeyChange{{Key: "mysql.url", Source: "env:APP_MYSQL_URL"}}})
+
+	out := buf.String()
+	for _, want := range []string{`"message":"config applied"`, `"version":3`, `"reason":"reload"`, `"key":"mysql.url"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output %s does not contain %s", out, want)
+		}
+	}
+}
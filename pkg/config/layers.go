@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Configuration layers are merged with the following precedence, highest
+// first:
+//
+//  1. command-line flags added with AddFlags (only flags explicitly set)
+//  2. environment variables added with AddEnv
+//  3. sources added with AddSource, later sources first
+//  4. the config file read by Load, see files.go
+//  5. defaults from SetDefaults and `default:"..."` struct tags
+//
+// Layers can be added in any order; the precedence does not depend on it.
+
+// applyTagDefaults registers the `default:"..."` struct tags of T as defaults.
+func (c *ConfigV[T]) applyTagDefaults() {
+	for _, f := range c.fields() {
+		if def, ok := f.tag.Lookup("default"); ok {
+			c.v.SetDefault(f.key, def)
+		}
+	}
+}
+
+// SetDefaults registers every reachable field of def as a default value.
+// Defaults set here take precedence over `default:"..."` struct tags.
+func (c *ConfigV[T]) SetDefaults(def *T) {
+	if def == nil {
+		return
+	}
+
+	root := reflect.ValueOf(def)
+	for _, f := range c.fields() {
+		if val, ok := fieldValue(root, f); ok {
+			c.v.SetDefault(f.key, val.Interface())
+		}
+	}
+}
+
+// AddEnv adds the environment layer. Every config key is bound to an
+// environment variable named after its key path, upper-cased, with dots
+// replaced by underscores and prefixed with prefix, e.g. "mysql.url" is read
+// from APP_MYSQL_URL when prefix is "app".
+func (c *ConfigV[T]) AddEnv(prefix string) error {
+	c.v.SetEnvPrefix(prefix)
+	c.v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	c.v.AutomaticEnv()
+	c.envEnabled = true
+
+	for _, f := range c.fields() {
+		if err := c.v.BindEnv(f.key); err != nil {
+			return fmt.Errorf("[ConfigV.AddEnv] failed to bind env for key '%s': %w.", f.key, err)
+		}
+	}
+
+	return nil
+}
+
+// AddFlags adds the command-line flag layer. Each flag is bound to the config
+// key equal to its name, e.g. the flag --mysql.url overrides "mysql.url".
+// Only flags explicitly set on the command line override other layers.
+func (c *ConfigV[T]) AddFlags(fs *pflag.FlagSet) error {
+	if err := c.v.BindPFlags(fs); err != nil {
+		return fmt.Errorf("[ConfigV.AddFlags] failed to bind flags: %w.", err)
+	}
+	c.flagSets = append(c.flagSets, fs)
+	return nil
+}
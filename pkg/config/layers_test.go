@@ -0,0 +1,88 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+type layeredConfig struct {
+	Name  string `default:"app"`
+	Level string `default:"info"`
+	Mysql struct {
+		Url  string
+		User string
+	}
+	Http *struct {
+		Port int `default:"80"`
+	}
+}
+
+func TestConfigV_Layers(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "level: warn\nmysql:\n  url: file:3306\n  user: file\n")
+
+	t.Setenv("LAYER_MYSQL_URL", "env:3306")
+	t.Setenv("LAYER_HTTP_PORT", "8080")
+
+	cv, err := NewConfigV[layeredConfig]()
+	if err != nil {
+		t.Fatalf("NewConfigV() error = %v", err)
+	}
+
+	def := &layeredConfig{Name: "defaults"}
+	def.Mysql.User = "default"
+	cv.SetDefaults(def)
+
+	if err := cv.AddEnv("layer"); err != nil {
+		t.Fatalf("AddEnv() error = %v", err)
+	}
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("mysql.url", "flag-default", "")
+	fs.String("level", "flag-default", "")
+	if err := fs.Parse([]string{"--mysql.url=flag:3306"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if err := cv.AddFlags(fs); err != nil {
+		t.Fatalf("AddFlags() error = %v", err)
+	}
+
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	conf := cv.Get()
+	tests := []struct {
+		key, got, want string
+	}{
+		{"name", conf.Name, "defaults"},
+		{"level", conf.Level, "warn"},
+		{"mysql.url", conf.Mysql.Url, "flag:3306"},
+		{"mysql.user", conf.Mysql.User, "file"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.key, tt.got, tt.want)
+		}
+	}
+	if conf.Http == nil || conf.Http.Port != 8080 {
+		t.Errorf("http.port = %+v, want 8080", conf.Http)
+	}
+}
+
+func TestConfigV_TagDefaultsWithoutFile(t *testing.T) {
+	cv, _ := NewConfigV[layeredConfig]()
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	conf := cv.Get()
+	if conf.Name != "app" || conf.Level != "info" {
+		t.Errorf("Get() = %+v, want tag defaults", conf)
+	}
+	if conf.Http == nil || conf.Http.Port != 80 {
+		t.Errorf("http.port = %+v, want 80", conf.Http)
+	}
+}
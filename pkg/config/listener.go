@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ReloadListener takes part in applying config changes in two phases, for
+// components that cannot accept every change, e.g. a server that must bind
+// a new port.
+//
+// Before a change is made current, Prepare is called on every listener in
+// registration order. If all of them accept it, the change is made current
+// and Commit is called on every listener. If one vetoes it, Abort is called
+// on the listeners that already accepted it, in reverse order, and the change
+// is discarded. A listener registered while a change is being applied is
+// not asked to accept it, but its Commit is called once the change is
+// current, so that reading the config after registering never misses one.
+//
+// The methods run synchronously while the load, reload or rollback is in
+// progress, so they must not call Load, Reload or Rollback.
+type ReloadListener[T any] interface {
+	// Prepare checks whether the change can be applied and may reserve the
+	// resources it needs. A non-nil error vetoes the change.
+	Prepare(e ChangeEvent[T]) error
+
+	// Commit applies the change after every listener accepted it.
+	Commit(e ChangeEvent[T])
+
+	// Abort releases what Prepare reserved when another listener vetoed
+	// the change.
+	Abort(e ChangeEvent[T])
+}
+
+// ReloadFuncs adapts functions to a ReloadListener. Nil functions are
+// skipped, and a nil Prepare accepts every change.
+type ReloadFuncs[T any] struct {
+	PrepareFunc func(e ChangeEvent[T]) error
+	CommitFunc  func(e ChangeEvent[T])
+	AbortFunc   func(e ChangeEvent[T])
+}
+
+// Prepare implements ReloadListener.
+func (f ReloadFuncs[T]) Prepare(e ChangeEvent[T]) error {
+	if f.PrepareFunc == nil {
+		return nil
+	}
+	return f.PrepareFunc(e)
+}
+
+// Commit implements ReloadListener.
+func (f ReloadFuncs[T]) Commit(e ChangeEvent[T]) {
+	if f.CommitFunc != nil {
+		f.CommitFunc(e)
+	}
+}
+
+// Abort implements ReloadListener.
+func (f ReloadFuncs[T]) Abort(e ChangeEvent[T]) {
+	if f.AbortFunc != nil {
+		f.AbortFunc(e)
+	}
+}
+
+// VetoError reports a change rejected by a ReloadListener. The current
+// config is kept.
+type VetoError struct {
+	Err error // error returned by Prepare.
+}
+
+func (e *VetoError) Error() string {
+	return fmt.Sprintf("config change vetoed, keeping the current one: %v", e.Err)
+}
+
+func (e *VetoError) Unwrap() error { return e.Err }
+
+// registration is a reload listener registered with AddReloadListener.
+type registration[T any] struct {
+	id uint64
+	l  ReloadListener[T]
+}
+
+// listeners holds the reload listeners of a ConfigV.
+type listeners[T any] struct {
+	mu     sync.Mutex
+	nextID uint64
+	regs   []registration[T]
+}
+
+// AddReloadListener registers l to take part in every change that touches
+// at least one key, see ReloadListener. The returned function removes the
+// listener and is safe to call more than once.
+func (c *ConfigV[T]) AddReloadListener(l ReloadListener[T]) (remove func()) {
+	ls := &c.listeners
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.nextID++
+	id := ls.nextID
+	ls.regs = append(ls.regs, registration[T]{id: id, l: l})
+
+	return func() {
+		ls.mu.Lock()
+		defer ls.mu.Unlock()
+
+		for i, reg := range ls.regs {
+			if reg.id == id {
+				ls.regs = append(ls.regs[:i:i], ls.regs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// prepare asks the reload listeners to accept e and returns the listeners
+// to commit it to, along with the ID of the last listener registered then.
+// If one vetoes it, the listeners that accepted it are aborted and a
+// VetoError is returned. The caller must hold c.mu.
+func (c *ConfigV[T]) prepare(e ChangeEvent[T]) ([]registration[T], uint64, error) {
+	if len(e.Keys) == 0 {
+		return nil, 0, nil
+	}
+
+	c.listeners.mu.Lock()
+	regs, last := c.listeners.regs, c.listeners.nextID
+	c.listeners.mu.Unlock()
+
+	for i, reg := range regs {
+		if err := reg.l.Prepare(e); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				regs[j].l.Abort(e)
+			}
+			return nil, 0, &VetoError{Err: err}
+		}
+	}
+	return regs, last, nil
+}
+
+// commit calls Commit on the listeners returned by prepare and then on the
+// listeners registered after last, once e is current. The caller must hold
+// c.mu.
+func (c *ConfigV[T]) commit(regs []registration[T], last uint64, e ChangeEvent[T]) {
+	if len(e.Keys) == 0 {
+		return
+	}
+
+	// A listener registered while e was being prepared may have read the
+	// config before e was made current, so it must not miss the commit.
+	c.listeners.mu.Lock()
+	var added []registration[T]
+	for _, reg := range c.listeners.regs {
+		if reg.id > last {
+			added = append(added, reg)
+		}
+	}
+	c.listeners.mu.Unlock()
+
+	for _, reg := range slices.Concat(regs, added) {
+		reg.l.Commit(e)
+	}
+}
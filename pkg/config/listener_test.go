@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// portListener records its calls and vetoes ports in use.
+type portListener struct {
+	name  string
+	inUse int
+	calls *[]string
+}
+
+func (l *portListener) Prepare(e ChangeEvent[testConfig]) error {
+	*l.calls = append(*l.calls, l.name+".prepare")
+	if e.New.Http.Port == l.inUse {
+		return errors.New("port already in use")
+	}
+	return nil
+}
+
+func (l *portListener) Commit(ChangeEvent[testConfig]) {
+	*l.calls = append(*l.calls, l.name+".commit")
+}
+
+func (l *portListener) Abort(ChangeEvent[testConfig]) {
+	*l.calls = append(*l.calls, l.name+".abort")
+}
+
+func TestConfigV_ReloadListener(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "http:\n  port: 80\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var calls []string
+	cv.AddReloadListener(&portListener{name: "a", calls: &calls})
+	remove := cv.AddReloadListener(&portListener{name: "b", inUse: 81, calls: &calls})
+	cv.AddReloadListener(&portListener{name: "c", calls: &calls})
+
+	notified := 0
+	cv.SetOnChange(func(ChangeEvent[testConfig]) { notified++ })
+
+	writeFile(t, file, "http:\n  port: 81\n")
+	err := cv.Reload()
+	var veto *VetoError
+	if !errors.As(err, &veto) {
+		t.Fatalf("Reload() error = %v, want VetoError", err)
+	}
+	if want := []string{"a.prepare", "b.prepare", "a.abort"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if got := cv.Get().Http.Port; got != 80 || notified != 0 {
+		t.Errorf("after veto http.port = %d, notified %d times, want 80 and 0", got, notified)
+	}
+	if st := cv.Status(); !errors.As(st.LastError, &veto) {
+		t.Errorf("Status().LastError = %v, want VetoError", st.LastError)
+	}
+
+	calls = nil
+	writeFile(t, file, "http:\n  port: 82\n")
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if want := []string{"a.prepare", "b.prepare", "c.prepare", "a.commit", "b.commit", "c.commit"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if got := cv.Get().Http.Port; got != 82 || notified != 1 {
+		t.Errorf("after commit http.port = %d, notified %d times, want 82 and 1", got, notified)
+	}
+
+	calls = nil
+	remove()
+	remove()
+	writeFile(t, file, "http:\n  port: 81\n")
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() after removing the vetoing listener error = %v", err)
+	}
+	if want := []string{"a.prepare", "c.prepare", "a.commit", "c.commit"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	calls = nil
+	if err := cv.Reload(); err != nil || len(calls) != 0 {
+		t.Errorf("Reload() without changes = %v, calls %v, want no listener calls", err, calls)
+	}
+}
+
+func TestConfigV_ReloadListenerAddedDuringReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "http:\n  port: 80\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// a adds b while the change is being prepared, i.e. after the
+	// listeners were asked and before the change is made current.
+	var calls []string
+	cv.AddReloadListener(ReloadFuncs[testConfig]{
+		PrepareFunc: func(ChangeEvent[testConfig]) error {
+			if len(calls) == 0 {
+				cv.AddReloadListener(&portListener{name: "b", calls: &calls})
+			}
+			calls = append(calls, "a.prepare")
+			return nil
+		},
+	})
+
+	writeFile(t, file, "http:\n  port: 81\n")
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if want := []string{"a.prepare", "b.commit"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestConfigV_ReloadListenerRollback(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "http:\n  port: 80\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	writeFile(t, file, "http:\n  port: 81\n")
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	cv.AddReloadListener(ReloadFuncs[testConfig]{
+		PrepareFunc: func(e ChangeEvent[testConfig]) error {
+			if e.New.Http.Port < 81 {
+				return errors.New("downgrade not allowed")
+			}
+			return nil
+		},
+	})
+
+	var veto *VetoError
+	if err := cv.Rollback(1); !errors.As(err, &veto) {
+		t.Errorf("Rollback() error = %v, want VetoError", err)
+	}
+	if got := cv.Get().Http.Port; got != 81 {
+		t.Errorf("http.port after vetoed rollback = %d, want 81", got)
+	}
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// VersionKey is the key of a config document holding the version of its
+// format. A document without it is version 1. The key is only interpreted
+// once a migration was added, so it is free for other uses until then.
+const VersionKey = "version"
+
+// Migration migrates config documents from version From to From+1, e.g. to
+// follow a renamed or moved key.
+//
+// Migrations run on every config file and source document before it is
+// merged, so older documents keep working after the config struct changed.
+type Migration struct {
+	From int
+
+	// Renames maps old key paths to their new key paths, e.g.
+	// "db.host": "mysql.host". The old keys are reported as deprecated.
+	Renames map[string]string
+
+	// Func transforms the document after Renames are applied, for changes
+	// that are not simple renames. It is optional.
+	Func func(doc map[string]any) error
+}
+
+// Deprecation reports a deprecated key found in a config document.
+type Deprecation struct {
+	Key         string // deprecated key path.
+	Replacement string // key path replacing it.
+	Version     int    // version the key was renamed in.
+}
+
+func (d Deprecation) String() string {
+	return fmt.Sprintf("key '%s' is deprecated since version %d, use '%s'", d.Key, d.Version, d.Replacement)
+}
+
+// AddMigration registers m. Migrations must be added before Load, one for
+// every version from 1 up to the latest version.
+func (c *ConfigV[T]) AddMigration(m Migration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if m.From < 1 {
+		return fmt.Errorf("[ConfigV.AddMigration] invalid version %d, versions start at 1.", m.From)
+	}
+	if slices.ContainsFunc(c.migrations, func(o Migration) bool { return o.From == m.From }) {
+		return fmt.Errorf("[ConfigV.AddMigration] migration from version %d already added.", m.From)
+	}
+
+	c.migrations = append(c.migrations, m)
+	slices.SortFunc(c.migrations, func(a, b Migration) int { return a.From - b.From })
+	return nil
+}
+
+// LatestVersion returns the version documents are migrated to.
+func (c *ConfigV[T]) LatestVersion() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return latestVersion(c.migrations)
+}
+
+func latestVersion(migrations []Migration) int {
+	if len(migrations) == 0 {
+		return 1
+	}
+	return migrations[len(migrations)-1].From + 1
+}
+
+// MigrationReport describes the migration of a config file.
+type MigrationReport struct {
+	File       string
+	From       int // version of the file before the migration.
+	To         int // version of the file after the migration.
+	Deprecated []Deprecation
+}
+
+// MigrateFile rewrites the config file at path to the latest version and
+// reports the deprecated keys it used. Includes and overlays are not
+// followed. Unless dryRun is set, a file that was not at the latest version
+// is rewritten atomically; comments and the order of keys are not kept.
+func (c *ConfigV[T]) MigrateFile(path string, dryRun bool) (*MigrationReport, error) {
+	c.mu.Lock()
+	fs, migrations := c.fs, c.migrations
+	c.mu.Unlock()
+
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("[ConfigV.MigrateFile] %w.", err)
+	}
+	format := strings.TrimPrefix(filepath.Ext(path), ".")
+	doc, err := parseConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("[ConfigV.MigrateFile] %w.", newParseError(path, data, err))
+	}
+
+	report := &MigrationReport{File: path, Deprecated: deprecations(doc, migrations)}
+	if report.From, err = migrate(doc, migrations); err != nil {
+		return nil, fmt.Errorf("[ConfigV.MigrateFile] %s: %w.", path, err)
+	}
+	report.To = latestVersion(migrations)
+	if dryRun || report.From == report.To {
+		return report, nil
+	}
+
+	out, err := encodeConfig(doc, format)
+	if err != nil {
+		return nil, fmt.Errorf("[ConfigV.MigrateFile] %w.", err)
+	}
+	if err := writeFileAtomic(fs, path, out); err != nil {
+		return nil, fmt.Errorf("[ConfigV.MigrateFile] %w.", err)
+	}
+	return report, nil
+}
+
+// migrate migrates doc in place to the latest version of migrations and
+// returns the version it had.
+func migrate(doc map[string]any, migrations []Migration) (int, error) {
+	if len(migrations) == 0 {
+		return 1, nil
+	}
+
+	version := 1
+	if raw, ok := doc[VersionKey]; ok {
+		v, err := strconv.Atoi(fmt.Sprint(raw))
+		if err != nil || v < 1 {
+			return 0, fmt.Errorf("invalid %s %v", VersionKey, raw)
+		}
+		version = v
+	}
+
+	latest := latestVersion(migrations)
+	if version > latest {
+		return 0, fmt.Errorf("%s %d is newer than the latest supported version %d", VersionKey, version, latest)
+	}
+	if version == latest {
+		return version, nil
+	}
+
+	for v := version; v < latest; v++ {
+		i := slices.IndexFunc(migrations, func(m Migration) bool { return m.From == v })
+		if i < 0 {
+			return 0, fmt.Errorf("no migration from %s %d", VersionKey, v)
+		}
+		m := migrations[i]
+
+		for _, old := range slices.Sorted(maps.Keys(m.Renames)) {
+			if val, ok := deleteKey(doc, old); ok {
+				setKey(doc, m.Renames[old], val)
+			}
+		}
+		if m.Func != nil {
+			if err := m.Func(doc); err != nil {
+				return 0, fmt.Errorf("failed to migrate from %s %d: %w", VersionKey, v, err)
+			}
+		}
+	}
+
+	doc[VersionKey] = latest
+	return version, nil
+}
+
+// deprecations returns the renamed keys of migrations used in doc.
+func deprecations(doc map[string]any, migrations []Migration) []Deprecation {
+	var out []Deprecation
+	for _, m := range migrations {
+		for _, old := range slices.Sorted(maps.Keys(m.Renames)) {
+			if _, ok := lookupKey(doc, old); ok {
+				out = append(out, Deprecation{Key: old, Replacement: m.Renames[old], Version: m.From + 1})
+			}
+		}
+	}
+	return out
+}
+
+func lookupKey(doc map[string]any, key string) (any, bool) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	m := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	val, ok := m[parts[len(parts)-1]]
+	return val, ok
+}
+
+// deleteKey removes key from doc, together with sections left empty, and
+// returns its value.
+func deleteKey(doc map[string]any, key string) (any, bool) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	if len(parts) == 1 {
+		val, ok := doc[parts[0]]
+		delete(doc, parts[0])
+		return val, ok
+	}
+
+	sub, ok := doc[parts[0]].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	val, ok := deleteKey(sub, strings.Join(parts[1:], "."))
+	if ok && len(sub) == 0 {
+		delete(doc, parts[0])
+	}
+	return val, ok
+}
+
+// setKey sets key in doc, creating the sections on the way.
+func setKey(doc map[string]any, key string, val any) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	m := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = val
+}
+
+// encodeConfig encodes settings in format, the inverse of parseConfig.
+func encodeConfig(settings map[string]any, format string) ([]byte, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := v.WriteConfigTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
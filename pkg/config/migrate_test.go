@@ -0,0 +1,232 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type migratedConfig struct {
+	Version int
+	Mysql   struct {
+		Host string
+		Port int
+	}
+	Http struct {
+		Addr string
+	}
+}
+
+// newMigratedConfig returns a ConfigV with two migrations: version 1 had
+// db.host and db.port, version 2 had http.host and http.port.
+func newMigratedConfig(t *testing.T) *ConfigV[migratedConfig] {
+	t.Helper()
+
+	cv, _ := NewConfigV[migratedConfig]()
+	migrations := []Migration{
+		{From: 2, Func: func(doc map[string]any) error {
+			http, _ := doc["http"].(map[string]any)
+			if host, ok := http["host"]; ok {
+				http["addr"] = fmt.Sprintf("%v:%v", host, http["port"])
+				delete(http, "host")
+				delete(http, "port")
+			}
+			return nil
+		}},
+		{From: 1, Renames: map[string]string{"db.host": "mysql.host", "db.port": "mysql.port"}},
+	}
+	for _, m := range migrations {
+		if err := cv.AddMigration(m); err != nil {
+			t.Fatalf("AddMigration() error = %v", err)
+		}
+	}
+	return cv
+}
+
+func TestConfigV_AddMigration(t *testing.T) {
+	cv := newMigratedConfig(t)
+	if got := cv.LatestVersion(); got != 3 {
+		t.Errorf("LatestVersion() = %d, want 3", got)
+	}
+	if err := cv.AddMigration(Migration{From: 1}); err == nil {
+		t.Error("AddMigration() of a duplicate version error = nil")
+	}
+	if err := cv.AddMigration(Migration{From: 0}); err == nil {
+		t.Error("AddMigration() of version 0 error = nil")
+	}
+}
+
+func TestConfigV_Migrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"version 1", "db:\n  host: db.local\n  port: 3306\nhttp:\n  host: 0.0.0.0\n  port: 80\n", ""},
+		{"version 2", "version: 2\nmysql:\n  host: db.local\n  port: 3306\nhttp:\n  host: 0.0.0.0\n  port: 80\n", ""},
+		{"latest", "version: 3\nmysql:\n  host: db.local\n  port: 3306\nhttp:\n  addr: 0.0.0.0:80\n", ""},
+		{"too new", "version: 4\n", "newer than the latest supported version 3"},
+		{"invalid", "version: v1\n", "invalid version v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "config.yaml"), tt.content)
+
+			cv := newMigratedConfig(t)
+			cv.SetStrict(StrictError)
+			err := cv.Load(dir, "config", "yaml")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			got := cv.Get()
+			if got.Version != 3 || got.Mysql.Host != "db.local" || got.Mysql.Port != 3306 || got.Http.Addr != "0.0.0.0:80" {
+				t.Errorf("Get() = %+v, want the migrated config", got)
+			}
+		})
+	}
+}
+
+func TestConfigV_MigrateWithSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"version 1", "db:\n  host: db.local\n  port: 3306\n", ""},
+		{"version 2", "version: 2\nhttp:\n  host: 0.0.0.0\n  port: 80\n", ""},
+		{"invalid after migration", "db:\n  port: abc\n", "key 'mysql.port' must be of type integer, got string"},
+		{"invalid latest", "version: 3\nmysql:\n  port: abc\n", "config.yaml:3:9: key 'mysql.port' must be of type integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "config.yaml"), tt.content)
+
+			cv := newMigratedConfig(t)
+			cv.SetSchemaValidation(true)
+			err := cv.Load(dir, "config", "yaml")
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Load() error = %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("renamed to a key of another type", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "config.yaml"), "http: localhost:80\n")
+
+		cv, _ := NewConfigV[struct {
+			Name string
+			Http struct{ Port int }
+		}]()
+		if err := cv.AddMigration(Migration{From: 1, Renames: map[string]string{"http": "name"}}); err != nil {
+			t.Fatalf("AddMigration() error = %v", err)
+		}
+		cv.SetSchemaValidation(true)
+		if err := cv.Load(dir, "config", "yaml"); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if got := cv.Get().Name; got != "localhost:80" {
+			t.Errorf("name = %q, want localhost:80", got)
+		}
+	})
+}
+
+func TestConfigV_VersionKeyWithoutMigrations(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "version: 1.2.3\n")
+
+	cv, _ := NewConfigV[struct{ Version string }]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cv.Get().Version; got != "1.2.3" {
+		t.Errorf("version = %q, want 1.2.3", got)
+	}
+}
+
+func TestConfigV_MigrateFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "db:\n  host: db.local\nhttp:\n  host: 0.0.0.0\n  port: 80\n"
+	writeFile(t, file, content)
+	cv := newMigratedConfig(t)
+
+	report, err := cv.MigrateFile(file, true)
+	if err != nil {
+		t.Fatalf("MigrateFile() dry run error = %v", err)
+	}
+	if report.From != 1 || report.To != 3 || len(report.Deprecated) != 1 || report.Deprecated[0].Key != "db.host" {
+		t.Errorf("MigrateFile() = %+v, want version 1 to 3 with db.host deprecated", report)
+	}
+	if data, _ := os.ReadFile(file); string(data) != content {
+		t.Errorf("dry run rewrote the file to %q", data)
+	}
+
+	if _, err := cv.MigrateFile(file, false); err != nil {
+		t.Fatalf("MigrateFile() error = %v", err)
+	}
+	doc, err := parseConfig(mustReadFile(t, file), "yaml")
+	if err != nil {
+		t.Fatalf("migrated file is not valid yaml: %v", err)
+	}
+	want := map[string]any{
+		"version": 3,
+		"mysql":   map[string]any{"host": "db.local"},
+		"http":    map[string]any{"addr": "0.0.0.0:80"},
+	}
+	if fmt.Sprint(doc) != fmt.Sprint(want) {
+		t.Errorf("migrated file = %v, want %v", doc, want)
+	}
+
+	report, err = cv.MigrateFile(file, false)
+	if err != nil || report.From != 3 || len(report.Deprecated) != 0 {
+		t.Errorf("MigrateFile() of a migrated file = %+v, %v, want version 3 without deprecations", report, err)
+	}
+}
+
+func TestNewMigrateCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, file, "db:\n  port: 3306\n")
+
+	cmd := NewMigrateCommand(newMigratedConfig(t))
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{file})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("migrate error = %v", err)
+	}
+
+	for _, want := range []string{
+		"migrated from version 1 to 3",
+		"key 'db.port' is deprecated since version 2, use 'mysql.port'",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output = %q, want %q", out.String(), want)
+		}
+	}
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
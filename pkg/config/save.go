@@ -0,0 +1,400 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// Set sets key to value in the config file the key is read from and applies
+// the change, see SetValues.
+func (c *ConfigV[T]) Set(key string, value any) error {
+	return c.SetValues(map[string]any{key: value})
+}
+
+// SetValues writes values, by key path, to the config files and applies them
+// like a reload. A key is written to the file its current value comes from,
+// e.g. an include or the profile overlay, and to the base config file loaded
+// by Load if it is not set in any file. Values set by other layers, such as
+// environment variables, still take precedence.
+//
+// Only YAML files can be written. Values are replaced and keys added in
+// place, keeping the rest of the file byte for byte; only edits that cannot
+// be made in place, such as replacing a section or a multi-line value,
+// re-encode the file, which keeps comments and the order of keys. Nothing is
+// written unless every file can be edited. Files are replaced atomically,
+// and restored if the new config is rejected, e.g. because it is invalid or
+// a ReloadListener vetoes it.
+func (c *ConfigV[T]) SetValues(values map[string]any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.configFile == "" {
+		return fmt.Errorf("[ConfigV.SetValues] no config file loaded.")
+	}
+
+	edits := make(map[string]map[string]any)
+	for key, val := range values {
+		file := c.configFile
+		if src, ok := strings.CutPrefix(c.keySources[strings.ToLower(key)], "file:"); ok {
+			file = src
+		}
+		if edits[file] == nil {
+			edits[file] = make(map[string]any)
+		}
+		edits[file][key] = val
+	}
+
+	files := slices.Sorted(maps.Keys(edits))
+	originals := make(map[string][]byte, len(files))
+	outputs := make(map[string][]byte, len(files))
+	for _, file := range files {
+		if ext := filepath.Ext(file); ext != ".yaml" && ext != ".yml" {
+			return fmt.Errorf("[ConfigV.SetValues] cannot write '%s', only YAML config files are supported.", file)
+		}
+		data, err := afero.ReadFile(c.fs, file)
+		if err != nil {
+			return fmt.Errorf("[ConfigV.SetValues] %w.", err)
+		}
+		out, err := setYAMLValues(data, edits[file])
+		if err != nil {
+			return fmt.Errorf("[ConfigV.SetValues] %s: %w.", file, err)
+		}
+		originals[file], outputs[file] = data, out
+	}
+
+	written := make(map[string][]byte, len(files))
+	for _, file := range files {
+		if err := writeFileAtomic(c.fs, file, outputs[file]); err != nil {
+			c.restoreFiles(written)
+			return fmt.Errorf("[ConfigV.SetValues] %w.", err)
+		}
+		written[file] = originals[file]
+	}
+
+	err := c.readConfig()
+	var event ChangeEvent[T]
+	if err == nil {
+		event, err = c.apply("set")
+	}
+	if err != nil {
+		c.restoreFiles(written)
+		return fmt.Errorf("[ConfigV.SetValues] %w", err)
+	}
+
+	if len(event.Keys) > 0 {
+		c.notify(event)
+	}
+	return nil
+}
+
+// restoreFiles writes back the original contents of files changed by
+// SetValues and re-reads them. The caller must hold c.mu.
+func (c *ConfigV[T]) restoreFiles(originals map[string][]byte) {
+	for file, data := range originals {
+		if err := writeFileAtomic(c.fs, file, data); err != nil {
+			c.log().Errorw("[ConfigV] failed to restore config file", "file", file, "error", err)
+		}
+	}
+	if len(originals) > 0 {
+		if err := c.readConfig(); err != nil {
+			c.log().Errorw("[ConfigV] failed to re-read restored config files", "error", err)
+		}
+	}
+}
+
+// splice replaces the bytes from start to end of a file with text.
+type splice struct {
+	start, end int
+	text       []byte
+}
+
+// insertion is a key added to a mapping of the original document.
+type insertion struct {
+	parent     *yaml.Node
+	key, value *yaml.Node
+}
+
+// setYAMLValues sets values, by key path, in the YAML document data. Scalars
+// replaced by scalars and keys added to block mappings are spliced into
+// data, so the rest of it is kept byte for byte; any other change
+// re-encodes the document.
+func setYAMLValues(data []byte, values map[string]any) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+
+	var (
+		splices    []splice
+		insertions []insertion
+		reencode   bool
+	)
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		var val yaml.Node
+		if err := val.Encode(values[key]); err != nil {
+			return nil, fmt.Errorf("key '%s': %w", key, err)
+		}
+
+		node, ins, restructured, err := lookupOrCreateNode(root, key)
+		if err != nil {
+			return nil, err
+		}
+		reencode = reencode || restructured
+		switch {
+		case ins != nil:
+			insertions = append(insertions, *ins)
+		case node.Line == 0:
+			// Added below a key added or replaced before, and written with it.
+		default:
+			s, ok := scalarSplice(data, node, &val)
+			splices = append(splices, s)
+			reencode = reencode || !ok
+		}
+		val.HeadComment, val.LineComment, val.FootComment = node.HeadComment, node.LineComment, node.FootComment
+		*node = val
+	}
+
+	// The added keys are rendered once every value is set, so that keys
+	// added below them are included.
+	for _, ins := range insertions {
+		s, ok := insertSplice(data, root, ins, indentOf(data))
+		splices = append(splices, s)
+		reencode = reencode || !ok
+	}
+
+	if reencode {
+		return encodeYAML(&doc, indentOf(data))
+	}
+
+	// Splices at the same offset are insertions, applied in reverse so
+	// that they end up in the order they were added.
+	slices.Reverse(splices)
+	slices.SortStableFunc(splices, func(a, b splice) int { return b.start - a.start })
+	out := slices.Clone(data)
+	for _, s := range splices {
+		out = slices.Concat(out[:s.start], s.text, out[s.end:])
+	}
+	return out, nil
+}
+
+// lookupOrCreateNode returns the value node of key in the mapping root,
+// adding the key and the sections on the way if they are missing. If a key
+// is added to a mapping of the original document, the insertion is
+// returned. A null section is turned into a mapping, which is reported as
+// restructured, since it cannot be spliced.
+func lookupOrCreateNode(root *yaml.Node, key string) (node *yaml.Node, ins *insertion, restructured bool, err error) {
+	node = root
+	parts := strings.Split(key, ".")
+	for i, name := range parts {
+		if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+			*node = yaml.Node{Kind: yaml.MappingNode}
+			restructured = true
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, nil, false, fmt.Errorf("cannot set '%s', '%s' is not a section", key, strings.Join(parts[:i], "."))
+		}
+
+		next := mappingValue(node, name)
+		if next == nil {
+			k := &yaml.Node{Kind: yaml.ScalarNode, Value: name}
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			if ins == nil && (node.Line != 0 || node == root) {
+				ins = &insertion{parent: node, key: k, value: next}
+			}
+			node.Content = append(node.Content, k, next)
+		}
+		node = next
+	}
+	return node, ins, restructured, nil
+}
+
+// insertSplice returns the splice adding the key of ins after the last line
+// of its parent mapping, indented like the other keys of the mapping.
+func insertSplice(data []byte, root *yaml.Node, ins insertion, indent int) (splice, bool) {
+	parent := ins.parent
+	if parent.Style&yaml.FlowStyle != 0 {
+		return splice{}, false
+	}
+
+	// The column of the first key of the mapping is its indentation. A root
+	// mapping added to an empty document has none.
+	column, line := 1, 0
+	if parent.Line != 0 {
+		first := parent.Content[0]
+		column, line = first.Column, first.Line
+	} else if parent != root {
+		return splice{}, false
+	}
+
+	// The mapping ends before the first line indented less than its keys,
+	// not counting blank lines and comments, which belong to what follows.
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	end, offset := len(data), 0
+	for i, l := range lines {
+		if i >= line-1 {
+			trimmed := bytes.TrimLeft(l, " ")
+			blank := len(bytes.TrimSpace(l)) == 0
+			if !blank && len(l)-len(trimmed) < column-1 && trimmed[0] != '#' {
+				break
+			}
+			if !blank && (trimmed[0] != '#' || len(l)-len(trimmed) >= column-1) {
+				end = offset + len(l)
+			}
+		}
+		offset += len(l)
+	}
+	if line == 0 {
+		end = len(data)
+	}
+
+	text, err := encodeYAML(&yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{ins.key, ins.value}}, indent)
+	if err != nil {
+		return splice{}, false
+	}
+	prefix := strings.Repeat(" ", column-1)
+	var buf bytes.Buffer
+	if end > 0 && data[end-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	for _, l := range bytes.SplitAfter(text, []byte("\n")) {
+		if len(l) > 0 {
+			buf.WriteString(prefix)
+			buf.Write(l)
+		}
+	}
+	return splice{start: end, end: end, text: buf.Bytes()}, true
+}
+
+// encodeYAML encodes node with the given indentation.
+func encodeYAML(node *yaml.Node, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mappingValue returns the value of the key name, compared
+// case-insensitively, in the mapping node, or nil.
+func mappingValue(node *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, name) {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scalarSplice returns the splice replacing the single-line scalar old in
+// data with val, keeping its quoting style.
+func scalarSplice(data []byte, old, val *yaml.Node) (splice, bool) {
+	if old.Kind != yaml.ScalarNode || val.Kind != yaml.ScalarNode {
+		return splice{}, false
+	}
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if old.Line < 1 || old.Line > len(lines) {
+		return splice{}, false
+	}
+	offset := 0
+	for _, l := range lines[:old.Line-1] {
+		offset += len(l)
+	}
+	line := lines[old.Line-1]
+
+	// Columns count characters, not bytes.
+	start := 0
+	for range old.Column - 1 {
+		if start >= len(line) {
+			return splice{}, false
+		}
+		_, size := utf8.DecodeRune(line[start:])
+		start += size
+	}
+	n := scalarLen(line[start:], old)
+	if n < 0 {
+		return splice{}, false
+	}
+
+	if quoted := yaml.SingleQuotedStyle | yaml.DoubleQuotedStyle; val.Tag == "!!str" && old.Style&quoted != 0 {
+		val.Style = old.Style
+	}
+	text, err := yaml.Marshal(val)
+	if err != nil {
+		return splice{}, false
+	}
+	text = bytes.TrimSuffix(text, []byte("\n"))
+	if bytes.ContainsRune(text, '\n') {
+		return splice{}, false
+	}
+	return splice{start: offset + start, end: offset + start + n, text: text}, true
+}
+
+// scalarLen returns the length in bytes of the scalar node at the start of
+// rest, or -1 if it does not end on the same line.
+func scalarLen(rest []byte, node *yaml.Node) int {
+	rest = bytes.TrimRight(rest, "\r\n")
+	switch node.Style {
+	case 0:
+		if node.Value == "" || !bytes.HasPrefix(rest, []byte(node.Value)) {
+			return -1
+		}
+		if after := rest[len(node.Value):]; len(after) > 0 && !strings.ContainsRune(" \t,]}", rune(after[0])) {
+			return -1
+		}
+		return len(node.Value)
+	case yaml.SingleQuotedStyle:
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == '\'' {
+				if i+1 < len(rest) && rest[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	case yaml.DoubleQuotedStyle:
+		for i := 1; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// indentOf returns the indentation used by the YAML document data, 2 if it
+// has no indented lines.
+func indentOf(data []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if n := len(line) - len(trimmed); n > 0 && trimmed != "" && trimmed[0] != '#' && (indent == 0 || n < indent) {
+			indent = n
+		}
+	}
+	if indent == 0 {
+		return 2
+	}
+	return indent
+}
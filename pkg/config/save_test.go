@@ -0,0 +1,247 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chhz0/going/pkg/logger/zlog"
+	"github.com/spf13/afero"
+)
+
+type saveConfig struct {
+	Env     string
+	Timeout time.Duration
+	Http    struct {
+		Host string
+		Port int `default:"80" validate:"min=1,max=65535"`
+	}
+	Mysql struct {
+		Url string
+	}
+}
+
+const saveFile = `# Service config.
+env: dev # the environment
+
+http:
+    # Listen address.
+    host: "localhost"
+    port: 80   # see the docs
+`
+
+func loadSaveConfig(t *testing.T, content string) (*ConfigV[saveConfig], string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), content)
+	cv, _ := NewConfigV[saveConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return cv, filepath.Join(dir, "config.yaml")
+}
+
+func TestConfigV_SetValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string // saveFile if empty.
+		values  map[string]any
+		want    string
+	}{
+		{
+			name:   "scalars in place",
+			values: map[string]any{"http.port": 8080, "http.host": "example.com", "env": "prod"},
+			want:   "# Service config.\nenv: prod # the environment\n\nhttp:\n    # Listen address.\n    host: \"example.com\"\n    port: 8080   # see the docs\n",
+		},
+		{
+			name:   "quoting a number-like string",
+			values: map[string]any{"env": "8080"},
+			want:   "# Service config.\nenv: \"8080\" # the environment\n\nhttp:\n    # Listen address.\n    host: \"localhost\"\n    port: 80   # see the docs\n",
+		},
+		{
+			name:   "new keys",
+			values: map[string]any{"mysql.url": "localhost:3306", "timeout": 5 * time.Second},
+			want:   saveFile + "mysql:\n    url: localhost:3306\ntimeout: 5s\n",
+		},
+		{
+			name:    "new keys between sections",
+			content: "http:\n  host: a # h\n\n# Database.\nmysql:\n  url: b\n",
+			values:  map[string]any{"http.port": 8080, "env": "prod"},
+			want:    "http:\n  host: a # h\n  port: 8080\n\n# Database.\nmysql:\n  url: b\nenv: prod\n",
+		},
+		{
+			name:    "null section",
+			content: "env: dev\nmysql:\n",
+			values:  map[string]any{"mysql.url": "x"},
+			want:    "env: dev\nmysql:\n  url: x\n",
+		},
+		{
+			name:    "empty file",
+			content: "# Only a comment.\n",
+			values:  map[string]any{"env": "dev"},
+			want:    "# Only a comment.\nenv: dev\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := tt.content
+			if content == "" {
+				content = saveFile
+			}
+			cv, file := loadSaveConfig(t, content)
+
+			var changed []string
+			cv.SetOnChange(func(e ChangeEvent[saveConfig]) { changed = e.Keys })
+			if err := cv.SetValues(tt.values); err != nil {
+				t.Fatalf("SetValues() error = %v", err)
+			}
+
+			if got := string(mustReadFile(t, file)); got != tt.want {
+				t.Errorf("file =\n%s\nwant\n%s", got, tt.want)
+			}
+			if len(changed) != len(tt.values) {
+				t.Errorf("changed keys = %v, want %d keys", changed, len(tt.values))
+			}
+			if h := cv.History(); h[len(h)-1].Reason != "set" {
+				t.Errorf("history reason = %q, want set", h[len(h)-1].Reason)
+			}
+		})
+	}
+}
+
+func TestConfigV_Set(t *testing.T) {
+	cv, file := loadSaveConfig(t, saveFile)
+
+	if err := cv.Set("http.port", 8080); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got := cv.Get().Http.Port; got != 8080 {
+		t.Errorf("http.port = %d, want 8080", got)
+	}
+
+	t.Run("invalid value", func(t *testing.T) {
+		before := string(mustReadFile(t, file))
+		if err := cv.Set("http.port", 70000); err == nil {
+			t.Fatal("Set() error = nil, want a validation error")
+		}
+		if got := string(mustReadFile(t, file)); got != before {
+			t.Errorf("file was not restored:\n%s", got)
+		}
+		if got := cv.Get().Http.Port; got != 8080 {
+			t.Errorf("http.port = %d, want 8080 to be kept", got)
+		}
+	})
+
+	t.Run("not a section", func(t *testing.T) {
+		if err := cv.Set("env.name", "x"); err == nil {
+			t.Error("Set() error = nil, want an error")
+		}
+	})
+}
+
+func TestConfigV_SetIncludedKey(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "include: db.yaml\nenv: dev\n")
+	writeFile(t, filepath.Join(dir, "db.yaml"), "mysql:\n  url: localhost:3306 # local\n")
+	cv, _ := NewConfigV[saveConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if err := cv.Set("mysql.url", "db:3306"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got, want := string(mustReadFile(t, filepath.Join(dir, "db.yaml"))), "mysql:\n  url: db:3306 # local\n"; got != want {
+		t.Errorf("db.yaml = %q, want %q", got, want)
+	}
+	if got, want := string(mustReadFile(t, filepath.Join(dir, "config.yaml"))), "include: db.yaml\nenv: dev\n"; got != want {
+		t.Errorf("config.yaml = %q, want it unchanged", got)
+	}
+	if got := cv.Get().Mysql.Url; got != "db:3306" {
+		t.Errorf("mysql.url = %q, want db:3306", got)
+	}
+}
+
+func TestConfigV_SetUnsupportedFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.json"), `{"env": "dev"}`)
+	cv, _ := NewConfigV[saveConfig]()
+	if err := cv.Load(dir, "config", "json"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if err := cv.Set("env", "prod"); err == nil {
+		t.Error("Set() error = nil, want an error for a JSON file")
+	}
+}
+
+func TestConfigV_SetValuesWritesNothingOnError(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), "include: z.json\nenv: a\n")
+	writeFile(t, filepath.Join(dir, "z.json"), `{"http": {"port": 1}}`)
+	cv, _ := NewConfigV[saveConfig]()
+	if err := cv.Load(dir, "a", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if err := cv.SetValues(map[string]any{"env": "b", "http.port": 2}); err == nil {
+		t.Fatal("SetValues() error = nil, want an error for the JSON file")
+	}
+	if got := string(mustReadFile(t, filepath.Join(dir, "a.yaml"))); got != "include: z.json\nenv: a\n" {
+		t.Errorf("a.yaml = %q, want it unchanged", got)
+	}
+	if got := cv.Get().Env; got != "a" {
+		t.Errorf("env = %q, want a", got)
+	}
+}
+
+// renameFailFs fails every Rename after the first n.
+type renameFailFs struct {
+	afero.Fs
+	n int
+}
+
+func (fs *renameFailFs) Rename(oldname, newname string) error {
+	if fs.n <= 0 {
+		return errors.New("rename failed")
+	}
+	fs.n--
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func TestConfigV_SetRestoreFails(t *testing.T) {
+	var logs bytes.Buffer
+	for name, logger := range map[string]zlog.Logger{
+		"default logger": nil,
+		"logger":         zlog.New(&logs, zlog.InfoLevel, zlog.JSONEncoder),
+	} {
+		t.Run(name, func(t *testing.T) {
+			fs := &renameFailFs{Fs: afero.NewMemMapFs(), n: 1}
+			if err := afero.WriteFile(fs, "/conf/config.yaml", []byte("http:\n  port: 80\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cv, _ := NewConfigV[saveConfig]()
+			cv.SetFs(fs)
+			cv.SetLogger(logger)
+			if err := cv.Load("/conf", "config", "yaml"); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if err := cv.Set("http.port", -5); err == nil {
+				t.Fatal("Set() error = nil, want a validation error")
+			}
+			if got := cv.Get().Http.Port; got != 80 {
+				t.Errorf("http.port = %d, want 80 to be kept", got)
+			}
+		})
+	}
+
+	if !strings.Contains(logs.String(), "failed to restore config file") {
+		t.Errorf("logs = %q, want the failed restore", logs.String())
+	}
+}
@@ -0,0 +1,394 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaDraft is the JSON Schema dialect generated by GenerateSchema.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document, limited to the keywords GenerateSchema
+// produces.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+	Default     any    `json:"default,omitempty"`
+	Enum        []any  `json:"enum,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	MinItems  *int     `json:"minItems,omitempty"`
+	MaxItems  *int     `json:"maxItems,omitempty"`
+}
+
+// GenerateSchema generates the JSON Schema of the config struct T. Property
+// names follow the config keys, named by the struct tag detected as described
+// at SetTagName, and the following struct tags are honored:
+//
+//	desc:"..."      description
+//	default:"..."   default
+//	validate:"..."  required, oneof as enum, min/max as bounds, url as format
+func GenerateSchema[T any]() *Schema {
+	typ := reflect.TypeFor[T]()
+	return generateSchema(typ, detectTagName(typ))
+}
+
+func generateSchema(typ reflect.Type, tagName string) *Schema {
+	s := typeSchema(typ, tagName, make(map[reflect.Type]bool))
+	s.Schema = SchemaDraft
+	return s
+}
+
+// JSONSchema returns the indented JSON Schema of T, e.g. to be referenced
+// by editors to autocomplete and validate config files.
+func (c *ConfigV[T]) JSONSchema() ([]byte, error) {
+	return json.MarshalIndent(generateSchema(reflect.TypeFor[T](), c.tagName), "", "  ")
+}
+
+// SetSchemaValidation enables validating every config file against the
+// schema of T before it is decoded. Errors report the file, line and column
+// of the offending value. The required keyword is not checked per file,
+// since required values may come from other files or layers; it is enforced
+// on the decoded config instead, see Validator.
+func (c *ConfigV[T]) SetSchemaValidation(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.schema = nil
+	if enabled {
+		c.schema = generateSchema(reflect.TypeFor[T](), c.tagName)
+	}
+}
+
+// typeSchema returns the schema of typ. path holds the structs being
+// described; a struct nested in itself is described as any object.
+func typeSchema(typ reflect.Type, tagName string, path map[reflect.Type]bool) *Schema {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == durationType:
+		return &Schema{Type: "string", Format: "duration"}
+	case typ == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case typ == byteSizeType:
+		// Either a number of bytes or a string with a unit.
+		return &Schema{Format: "byte-size"}
+	case isTextValue(typ):
+		return &Schema{Type: "string"}
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: typeSchema(typ.Elem(), tagName, path)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: typeSchema(typ.Elem(), tagName, path)}
+	case reflect.Struct:
+		if path[typ] {
+			return &Schema{Type: "object"}
+		}
+		path[typ] = true
+		defer delete(path, typ)
+
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		addProperties(s, typ, tagName, path)
+		return s
+	default:
+		return &Schema{}
+	}
+}
+
+func addProperties(s *Schema, typ reflect.Type, tagName string, path map[reflect.Type]bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, squash, skip := fieldKey(sf, tagName)
+		if skip {
+			continue
+		}
+		if squash {
+			ft := sf.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if !path[ft] {
+				path[ft] = true
+				addProperties(s, ft, tagName, path)
+				delete(path, ft)
+			}
+			continue
+		}
+
+		prop := typeSchema(sf.Type, tagName, path)
+		prop.Description = sf.Tag.Get("desc")
+		if def, ok := sf.Tag.Lookup("default"); ok {
+			prop.Default = schemaValue(prop, def)
+		}
+		if applyRules(prop, sf.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// applyRules maps validate rules to schema keywords and reports whether the
+// field is required.
+func applyRules(s *Schema, tag string) (required bool) {
+	if tag == "" {
+		return false
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "required":
+			required = true
+		case "oneof":
+			for _, v := range strings.Fields(arg) {
+				s.Enum = append(s.Enum, schemaValue(s, v))
+			}
+		case "url":
+			s.Format = "uri"
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			isMin := name == "min"
+			switch s.Type {
+			case "integer", "number":
+				if isMin {
+					s.Minimum = &n
+				} else {
+					s.Maximum = &n
+				}
+			case "string":
+				if isMin {
+					s.MinLength = ptrInt(n)
+				} else {
+					s.MaxLength = ptrInt(n)
+				}
+			case "array":
+				if isMin {
+					s.MinItems = ptrInt(n)
+				} else {
+					s.MaxItems = ptrInt(n)
+				}
+			}
+		}
+	}
+
+	return required
+}
+
+func ptrInt(f float64) *int {
+	i := int(f)
+	return &i
+}
+
+// schemaValue converts the tag value raw to the JSON type of s.
+func schemaValue(s *Schema, raw string) any {
+	switch s.Type {
+	case "integer":
+		if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return i
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	case "array":
+		items := make([]any, 0)
+		for _, v := range strings.Split(raw, ",") {
+			items = append(items, schemaValue(s.Items, v))
+		}
+		return items
+	}
+	return raw
+}
+
+// validateFile validates data, the content of the YAML or JSON config file,
+// against s.
+func validateFile(file string, data []byte, s *Schema) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		// viper reports syntax errors itself.
+		return nil
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	return validateDocument(file, doc.Content[0], s, true)
+}
+
+// validateSettings validates settings, as read from the config file, against
+// s. The errors carry no position, since settings are not what the file
+// contains if they were migrated from an older version, and positions are
+// only known for YAML and JSON files.
+func validateSettings(file string, settings map[string]any, s *Schema) error {
+	var doc yaml.Node
+	if err := doc.Encode(settings); err != nil {
+		return err
+	}
+	return validateDocument(file, &doc, s, false)
+}
+
+// validateDocument validates the root node of a document of the config file
+// against s. With positions set, errors report the position of the node.
+func validateDocument(file string, root *yaml.Node, s *Schema, positions bool) error {
+	var errs []error
+	validateNode(root, s, "", func(node *yaml.Node, key, msg string) {
+		err := &ValidationError{Key: key, Source: "file:" + file, File: file, Err: errors.New(msg)}
+		if positions {
+			err.Line, err.Column = node.Line, node.Column
+		}
+		errs = append(errs, err)
+	})
+	return errors.Join(errs...)
+}
+
+func validateNode(node *yaml.Node, s *Schema, key string, report func(node *yaml.Node, key, msg string)) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if s == nil || (node.Kind == yaml.ScalarNode && node.Tag == "!!null") {
+		return
+	}
+	// References and encrypted values only get their type once resolved,
+	// which happens after the files are read.
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" && (refPattern.MatchString(node.Value) || IsEncrypted(node.Value)) {
+		return
+	}
+
+	got := nodeType(node)
+	if s.Type != "" && !typeMatches(s.Type, got) {
+		report(node, key, fmt.Sprintf("must be of type %s, got %s", s.Type, got))
+		return
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name := strings.ToLower(node.Content[i].Value)
+			child := key + "." + name
+			if key == "" {
+				child = name
+			}
+
+			prop, ok := s.Properties[name]
+			if !ok {
+				prop = s.AdditionalProperties
+			}
+			validateNode(node.Content[i+1], prop, child, report)
+		}
+	case yaml.SequenceNode:
+		n := float64(len(node.Content))
+		checkRange(node, key, n, s.MinItems, s.MaxItems, "number of items", report)
+		for _, item := range node.Content {
+			validateNode(item, s.Items, key, report)
+		}
+	case yaml.ScalarNode:
+		validateScalar(node, s, key, report)
+	}
+}
+
+func validateScalar(node *yaml.Node, s *Schema, key string, report func(node *yaml.Node, key, msg string)) {
+	var val any
+	if err := node.Decode(&val); err != nil {
+		return
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(val) }) {
+		report(node, key, fmt.Sprintf("must be one of %v, got '%v'", s.Enum, val))
+	}
+
+	switch v := val.(type) {
+	case string:
+		checkRange(node, key, float64(len(v)), s.MinLength, s.MaxLength, "length", report)
+	case int:
+		checkBounds(node, key, float64(v), s, report)
+	case float64:
+		checkBounds(node, key, v, s, report)
+	}
+}
+
+func checkBounds(node *yaml.Node, key string, v float64, s *Schema, report func(node *yaml.Node, key, msg string)) {
+	if s.Minimum != nil && v < *s.Minimum {
+		report(node, key, fmt.Sprintf("must be at least %v, got %v", *s.Minimum, v))
+	}
+	if s.Maximum != nil && v > *s.Maximum {
+		report(node, key, fmt.Sprintf("must be at most %v, got %v", *s.Maximum, v))
+	}
+}
+
+func checkRange(node *yaml.Node, key string, n float64, min, max *int, what string, report func(node *yaml.Node, key, msg string)) {
+	if min != nil && n < float64(*min) {
+		report(node, key, fmt.Sprintf("%s must be at least %d", what, *min))
+	}
+	if max != nil && n > float64(*max) {
+		report(node, key, fmt.Sprintf("%s must be at most %d", what, *max))
+	}
+}
+
+// nodeType returns the JSON type of node.
+func nodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+
+	switch node.Tag {
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		if f, err := strconv.ParseFloat(node.Value, 64); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+func typeMatches(want, got string) bool {
+	return want == got || (want == "number" && got == "integer")
+}
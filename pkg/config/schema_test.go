@@ -0,0 +1,141 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type schemaConfig struct {
+	Env     string        `desc:"deployment environment" validate:"required,oneof=dev prod"`
+	Timeout time.Duration `default:"5s"`
+	Http    struct {
+		Host string `default:"localhost"`
+		Port int    `default:"80" validate:"min=1,max=65535"`
+	}
+	Mysql *struct {
+		Url string `validate:"url"`
+	}
+	Tags   []string `default:"a,b"`
+	Labels map[string]string
+	Ignore string `mapstructure:"-"`
+}
+
+func TestGenerateSchema(t *testing.T) {
+	s := GenerateSchema[schemaConfig]()
+
+	if s.Schema != SchemaDraft || s.Type != "object" {
+		t.Errorf("root = %+v, want object with $schema", s)
+	}
+	if len(s.Required) != 1 || s.Required[0] != "env" {
+		t.Errorf("required = %v, want [env]", s.Required)
+	}
+	if _, ok := s.Properties["ignore"]; ok {
+		t.Error("ignored field is in the schema")
+	}
+
+	env := s.Properties["env"]
+	if env.Description != "deployment environment" || len(env.Enum) != 2 {
+		t.Errorf("env = %+v, want description and enum", env)
+	}
+
+	port := s.Properties["http"].Properties["port"]
+	if port.Type != "integer" || port.Default != int64(80) || *port.Minimum != 1 || *port.Maximum != 65535 {
+		t.Errorf("http.port = %+v, want integer default 80 in [1, 65535]", port)
+	}
+	if url := s.Properties["mysql"].Properties["url"]; url.Format != "uri" {
+		t.Errorf("mysql.url format = %q, want uri", url.Format)
+	}
+	if tags := s.Properties["tags"]; tags.Type != "array" || tags.Items.Type != "string" || len(tags.Default.([]any)) != 2 {
+		t.Errorf("tags = %+v, want string array with default", tags)
+	}
+	if labels := s.Properties["labels"]; labels.AdditionalProperties == nil || labels.AdditionalProperties.Type != "string" {
+		t.Errorf("labels = %+v, want map of strings", labels)
+	}
+
+	list := GenerateSchema[struct{ Head listNode }]().Properties["head"]
+	if next := list.Properties["next"]; next == nil || next.Type != "object" || next.Properties != nil {
+		t.Errorf("head.next = %+v, want an object without properties", next)
+	}
+
+	cv, _ := NewConfigV[schemaConfig]()
+	data, err := cv.JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema() error = %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("JSONSchema() is not valid json: %v", err)
+	}
+}
+
+func TestConfigV_SchemaValidation(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), `env: dev
+http:
+  host: localhost
+  port: "eighty"
+mysql:
+  url: mysql://db
+tags: [a, 1]
+`)
+	writeFile(t, filepath.Join(dir, "config.prod.yaml"), "http:\n  port: 70000\n")
+
+	cv, _ := NewConfigV[schemaConfig]()
+	cv.SetSchemaValidation(true)
+	err := cv.Load(dir, "config", "yaml")
+	if err == nil {
+		t.Fatal("Load() error = nil, want schema error")
+	}
+	if want := "config.yaml:4:9: key 'http.port' must be of type integer, got string"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Load() error = %v, want %q", err, want)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), "env: prod\nhttp:\n  port: 80\n")
+	err = cv.Load(dir, "config", "yaml")
+	if want := "config.prod.yaml:2:9: key 'http.port' must be at most 65535"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Load() error = %v, want %q", err, want)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.prod.yaml"), "http:\n  port: 8080\n")
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Errorf("Load() error = %v, want nil", err)
+	}
+
+	t.Setenv("SCHEMA_TEST_PORT", "8081")
+	writeFile(t, filepath.Join(dir, "config.prod.yaml"), "http:\n  port: ${env:SCHEMA_TEST_PORT}\n")
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Errorf("Load() with a reference error = %v, want nil", err)
+	}
+	if got := cv.Get().Http.Port; got != 8081 {
+		t.Errorf("http.port = %d, want 8081", got)
+	}
+
+	schema := generateSchema(reflect.TypeFor[schemaConfig](), "mapstructure")
+	if err := validateFile("config.yaml", []byte("http:\n  port: "+EncryptedPrefix+"AAAA\n"), schema); err != nil {
+		t.Errorf("validateFile() with an encrypted value error = %v, want nil", err)
+	}
+}
+
+func TestConfigV_SchemaValidationTOML(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.toml"), "env = \"dev\"\n\n[http]\nport = 80\n")
+
+	cv, _ := NewConfigV[schemaConfig]()
+	cv.SetSchemaValidation(true)
+	if err := cv.Load(dir, "config", "toml"); err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	if got := cv.Get().Http.Port; got != 80 {
+		t.Errorf("http.port = %d, want 80", got)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.toml"), "env = \"dev\"\n\n[http]\nport = \"eighty\"\n")
+	err := cv.Load(dir, "config", "toml")
+	if want := "config.toml: key 'http.port' must be of type integer, got string"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Load() error = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Resolver resolves the reference part of a ${scheme:ref} value.
+type Resolver interface {
+	Resolve(ref string) (string, error)
+}
+
+// ResolverFunc adapts an ordinary function to a Resolver.
+type ResolverFunc func(ref string) (string, error)
+
+// Resolve calls f(ref).
+func (f ResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+// EnvResolver resolves ${env:NAME} to the value of the environment variable
+// NAME. An unset variable is an error.
+var EnvResolver = ResolverFunc(func(name string) (string, error) {
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable '%s' is not set", name)
+	}
+	return val, nil
+})
+
+// FileResolver resolves ${file:PATH} to the content of the file at PATH with
+// trailing newlines removed, e.g. a Docker or Kubernetes secret mount.
+var FileResolver = ResolverFunc(func(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+})
+
+// refPattern matches value references such as ${env:DB_PASSWORD}.
+var refPattern = regexp.MustCompile(`\$\{([a-zA-Z][a-zA-Z0-9_-]*):([^}]*)\}`)
+
+// RegisterResolver registers r for references of the form ${scheme:ref}.
+// The schemes "env" and "file" are registered by default and can be
+// replaced. Resolvers must be registered before Load.
+func (c *ConfigV[T]) RegisterResolver(scheme string, r Resolver) {
+	c.resolvers[scheme] = r
+}
+
+// IsSecret reports whether the value of key is a secret, either because the
+// field is tagged `secret:"true"` or has type Secret, or because it was
+// resolved from a reference or decrypted. Secret values must never be
+// logged or dumped.
+func (c *ConfigV[T]) IsSecret(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := (*c.secrets.Load())[key]; ok {
+		return true
+	}
+
+	for _, f := range c.fields() {
+		if f.key == key && (f.tag.Get("secret") == "true" || f.typ == secretType) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRefs replaces every reference in the string values of settings and
+// decrypts encrypted values, in place, and returns the key paths of the
+// values that were resolved or decrypted.
+func (c *ConfigV[T]) resolveRefs(settings map[string]any) (map[string]struct{}, error) {
+	secrets := make(map[string]struct{})
+	if err := c.resolveMap(settings, "", secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (c *ConfigV[T]) resolveMap(m map[string]any, prefix string, secrets map[string]struct{}) error {
+	for k, val := range m {
+		resolved, err := c.resolveValue(val, prefix+k, secrets)
+		if err != nil {
+			return err
+		}
+		m[k] = resolved
+	}
+	return nil
+}
+
+func (c *ConfigV[T]) resolveValue(val any, key string, secrets map[string]struct{}) (any, error) {
+	switch val := val.(type) {
+	case map[string]any:
+		return val, c.resolveMap(val, key+".", secrets)
+	case []any:
+		out := make([]any, len(val))
+		for i, elem := range val {
+			resolved, err := c.resolveValue(elem, key, secrets)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	case string:
+		if IsEncrypted(val) {
+			if c.cipher == nil {
+				return nil, fmt.Errorf("key '%s' holds an encrypted value but no decryption key is set", key)
+			}
+			plain, err := c.cipher.Decrypt(val)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt key '%s': %w", key, err)
+			}
+			secrets[key] = struct{}{}
+			return plain, nil
+		}
+		if !refPattern.MatchString(val) {
+			return val, nil
+		}
+
+		var errs []error
+		out := refPattern.ReplaceAllStringFunc(val, func(ref string) string {
+			m := refPattern.FindStringSubmatch(ref)
+			r, ok := c.resolvers[m[1]]
+			if !ok {
+				errs = append(errs, fmt.Errorf("unknown reference scheme '%s'", m[1]))
+				return ref
+			}
+			resolved, err := r.Resolve(m[2])
+			if err != nil {
+				errs = append(errs, err)
+				return ref
+			}
+			return resolved
+		})
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("failed to resolve reference for key '%s': %w", key, errs[0])
+		}
+
+		secrets[key] = struct{}{}
+		return out, nil
+	default:
+		return val, nil
+	}
+}
+
+const redacted = "******"
+
+var secretType = reflect.TypeFor[Secret]()
+
+// Secret is a string whose value is redacted when it is formatted, e.g. by
+// fmt or a logger, or marshalled to JSON or YAML. Use Value to read it.
+type Secret string
+
+// Value returns the plain secret value.
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String implements fmt.Stringer and returns a redacted placeholder.
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString implements fmt.GoStringer and returns a redacted placeholder.
+func (s Secret) GoString() string {
+	return `"` + redacted + `"`
+}
+
+// MarshalJSON implements json.Marshaler and writes a redacted placeholder.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+// MarshalYAML implements yaml.Marshaler and writes a redacted placeholder.
+func (s Secret) MarshalYAML() (any, error) {
+	return redacted, nil
+}
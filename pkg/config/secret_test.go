@@ -0,0 +1,128 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type secretConfig struct {
+	Mysql struct {
+		Url      string
+		User     string
+		Password Secret
+		Dsn      string
+	}
+	Token string `secret:"true"`
+	Hosts []string
+}
+
+func TestConfigV_ResolveRefs(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "db_password"), "s3cret\n")
+	writeFile(t, filepath.Join(dir, "config.yaml"), fmt.Sprintf(`
+mysql:
+  url: localhost:3306
+  user: ${env:TEST_DB_USER}
+  password: ${file:%s}
+  dsn: mysql://${env:TEST_DB_USER}:${vault:db/pw}@localhost
+hosts: [a, "${env:TEST_DB_USER}"]
+`, filepath.Join(dir, "db_password")))
+	t.Setenv("TEST_DB_USER", "root")
+
+	cv, _ := NewConfigV[secretConfig]()
+	cv.RegisterResolver("vault", ResolverFunc(func(ref string) (string, error) {
+		return "vault:" + ref, nil
+	}))
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	conf := cv.Get()
+	if conf.Mysql.User != "root" || conf.Mysql.Password.Value() != "s3cret" {
+		t.Errorf("mysql = %+v, want resolved user and password", conf.Mysql)
+	}
+	if want := "mysql://root:vault:db/pw@localhost"; conf.Mysql.Dsn != want {
+		t.Errorf("mysql.dsn = %q, want %q", conf.Mysql.Dsn, want)
+	}
+	if conf.Hosts[1] != "root" {
+		t.Errorf("hosts = %v, want resolved element", conf.Hosts)
+	}
+
+	for key, want := range map[string]bool{
+		"mysql.user":     true,
+		"mysql.password": true,
+		"mysql.dsn":      true,
+		"token":          true,
+		"mysql.url":      false,
+	} {
+		if got := cv.IsSecret(key); got != want {
+			t.Errorf("IsSecret(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestConfigV_ResolveRefsError(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "mysql:\n  user: ${env:TEST_UNSET_VARIABLE}\n")
+
+	cv, _ := NewConfigV[secretConfig]()
+	err := cv.Load(dir, "config", "yaml")
+	if err == nil || !strings.Contains(err.Error(), "mysql.user") {
+		t.Errorf("Load() error = %v, want unresolved reference error for mysql.user", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), "mysql:\n  user: ${nope:x}\n")
+	if err := cv.Reload(); err == nil || !strings.Contains(err.Error(), "unknown reference scheme") {
+		t.Errorf("Reload() error = %v, want unknown scheme error", err)
+	}
+}
+
+func TestSecret_Redacted(t *testing.T) {
+	var conf secretConfig
+	conf.Mysql.Password = "s3cret"
+
+	for _, s := range []string{
+		fmt.Sprint(conf.Mysql.Password),
+		fmt.Sprintf("%+v", conf),
+		fmt.Sprintf("%#v", conf),
+	} {
+		if strings.Contains(s, "s3cret") {
+			t.Errorf("formatted value %q leaks the secret", s)
+		}
+	}
+
+	data, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("json %s leaks the secret", data)
+	}
+}
+
+func TestConfigV_SecretErrorsRedacted(t *testing.T) {
+	type pinConfig struct {
+		Pin  int
+		Mode string `validate:"oneof=a b"`
+	}
+
+	t.Setenv("TEST_PIN", "hunter2")
+	t.Setenv("TEST_MODE", "hunter3")
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "pin: ${env:TEST_PIN}\n")
+
+	cv, _ := NewConfigV[pinConfig]()
+	err := cv.Load(dir, "config", "yaml")
+	if err == nil || !strings.Contains(err.Error(), "key 'pin'") || strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("Load() error = %v, want a decode error for pin without the value", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), "pin: 1\nmode: ${env:TEST_MODE}\n")
+	err = cv.Reload()
+	if err == nil || !strings.Contains(err.Error(), "got '******'") || strings.Contains(err.Error(), "hunter3") {
+		t.Errorf("Reload() error = %v, want a validation error for mode without the value", err)
+	}
+}
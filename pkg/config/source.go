@@ -0,0 +1,252 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Source provides a configuration document from outside the local config
+// file, e.g. from a central configuration service.
+//
+// Documents read from sources go through the same resolve, decode, validate
+// and notify pipeline as the config file.
+type Source interface {
+	// Name identifies the source in errors and in the provenance reported by
+	// Explain, e.g. "memory" or the URL of an HTTP source.
+	Name() string
+
+	// Format returns the format of the document, e.g. "yaml" or "json".
+	Format() string
+
+	// Read returns the current document.
+	Read(ctx context.Context) ([]byte, error)
+
+	// Watch blocks until ctx is done or the source is closed, calling notify
+	// whenever the document may have changed. A source failing to fetch the
+	// document calls notify too and returns the error from the next Read, so
+	// that the failed reload is reported.
+	Watch(ctx context.Context, notify func()) error
+
+	// Close releases the source and stops Watch.
+	Close() error
+}
+
+// AddSource adds src to the config layer. Sources are merged on top of the
+// config file in the order they were added, so later sources win. Sources
+// must be added before Load, or before Reload when no config file is used.
+func (c *ConfigV[T]) AddSource(src Source) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sources = append(c.sources, src)
+}
+
+// Close closes every source added with AddSource.
+func (c *ConfigV[T]) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for _, src := range c.sources {
+		if err := src.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("source '%s': %w", src.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readSource merges the document of src into l.
+func (l *fileLayer) readSource(src Source) error {
+	data, err := src.Read(context.Background())
+	if err != nil {
+		return err
+	}
+
+	settings, err := parseConfig(data, src.Format())
+	if err != nil {
+		return newParseError(src.Name(), data, err)
+	}
+	if _, err := migrate(settings, l.migrations); err != nil {
+		return err
+	}
+
+	mergeSettings(l.settings, settings, "", src.Name(), l.sources)
+	return nil
+}
+
+// parseConfig parses data in any format supported by viper.
+func parseConfig(data []byte, format string) (map[string]any, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+// closer implements the Close half of Source for the sources in this package.
+type closer struct {
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newCloser() closer {
+	return closer{closed: make(chan struct{})}
+}
+
+func (c *closer) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+// MemorySource is a Source holding a document in memory. Set replaces the
+// document and notifies watchers, which makes it handy in tests.
+type MemorySource struct {
+	closer
+
+	format string
+
+	mu       sync.Mutex
+	data     []byte
+	watchers []chan struct{}
+}
+
+// NewMemorySource returns a MemorySource holding data in the given format.
+func NewMemorySource(format string, data []byte) *MemorySource {
+	return &MemorySource{closer: newCloser(), format: format, data: data}
+}
+
+// Name implements Source.
+func (s *MemorySource) Name() string { return "memory" }
+
+// Format implements Source.
+func (s *MemorySource) Format() string { return s.format }
+
+// Read implements Source.
+func (s *MemorySource) Read(context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return bytes.Clone(s.data), nil
+}
+
+// Set replaces the document and notifies the running watchers.
+func (s *MemorySource) Set(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = bytes.Clone(data)
+	for _, ch := range s.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Watch implements Source.
+func (s *MemorySource) Watch(ctx context.Context, notify func()) error {
+	ch := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	s.watchers = append(s.watchers, ch)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.watchers = slices.DeleteFunc(s.watchers, func(w chan struct{}) bool { return w == ch })
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.closed:
+			return nil
+		case <-ch:
+			notify()
+		}
+	}
+}
+
+// FileSource is a Source reading a single local file. Unlike the config
+// file passed to Load, it supports neither includes nor profile overlays.
+type FileSource struct {
+	closer
+
+	path   string
+	format string
+}
+
+// NewFileSource returns a FileSource for path. The format is taken from the
+// file extension.
+func NewFileSource(path string) *FileSource {
+	return &FileSource{
+		closer: newCloser(),
+		path:   filepath.Clean(path),
+		format: strings.TrimPrefix(filepath.Ext(path), "."),
+	}
+}
+
+// Name implements Source.
+func (s *FileSource) Name() string { return "file:" + s.path }
+
+// Format implements Source.
+func (s *FileSource) Format() string { return s.format }
+
+// Read implements Source.
+func (s *FileSource) Read(context.Context) ([]byte, error) {
+	return os.ReadFile(s.path)
+}
+
+// Watch implements Source. Only changes of the file content are notified.
+func (s *FileSource) Watch(ctx context.Context, notify func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		return err
+	}
+
+	var last [sha256.Size]byte
+	if data, err := os.ReadFile(s.path); err == nil {
+		last = sha256.Sum256(data)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.closed:
+			return nil
+		case err := <-watcher.Errors:
+			return err
+		case event := <-watcher.Events:
+			if filepath.Clean(event.Name) != s.path {
+				continue
+			}
+
+			data, err := os.ReadFile(s.path)
+			if err != nil {
+				continue
+			}
+			if sum := sha256.Sum256(data); sum != last {
+				last = sum
+				notify()
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPSourceOptions configures an HTTPSource.
+type HTTPSourceOptions struct {
+	// Client sends the requests; a client with a 30s timeout is used if nil.
+	Client *http.Client
+
+	// Interval is the delay between two polls. With LongPoll it is only the
+	// delay before the first request. Defaults to 30s.
+	Interval time.Duration
+
+	// LongPoll tells Watch that the server holds a request carrying an
+	// If-None-Match header until the document changes or a timeout elapses,
+	// so requests are sent back to back.
+	LongPoll bool
+
+	// MaxBackoff caps the delay between retries after failed requests.
+	// The delay starts at one second and doubles. Defaults to one minute.
+	MaxBackoff time.Duration
+}
+
+// HTTPSource is a Source fetching a document from an HTTP endpoint, e.g. a
+// central configuration service. Watch polls the endpoint, or long-polls it,
+// using ETag and If-None-Match to detect changes, and backs off on failures.
+// A failed poll is passed on to the reload it triggers, so that it is
+// reported by ConfigV.Watch and counted by ConfigV.Status.
+type HTTPSource struct {
+	closer
+
+	url    string
+	format string
+	opts   HTTPSourceOptions
+
+	mu    sync.Mutex
+	etag  string
+	body  []byte
+	fresh bool  // body was fetched by Watch and not read yet.
+	err   error // error of the last poll by Watch, not read yet.
+}
+
+// NewHTTPSource returns an HTTPSource for the document in the given format
+// served at url.
+func NewHTTPSource(url, format string, opts HTTPSourceOptions) *HTTPSource {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Minute
+	}
+
+	return &HTTPSource{closer: newCloser(), url: url, format: format, opts: opts}
+}
+
+// Name implements Source.
+func (s *HTTPSource) Name() string { return s.url }
+
+// Format implements Source.
+func (s *HTTPSource) Format() string { return s.format }
+
+// Read implements Source. The first Read after Watch detected a change
+// returns the document Watch fetched instead of requesting it again, so the
+// reload sees the document that triggered it. Likewise, the first Read after
+// a failed poll returns its error.
+func (s *HTTPSource) Read(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	if err := s.err; err != nil {
+		s.err = nil
+		s.mu.Unlock()
+		return nil, err
+	}
+	if s.fresh {
+		s.fresh = false
+		defer s.mu.Unlock()
+		return bytes.Clone(s.body), nil
+	}
+	s.mu.Unlock()
+
+	if _, err := s.fetch(ctx, false); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return bytes.Clone(s.body), nil
+}
+
+// Watch implements Source.
+func (s *HTTPSource) Watch(ctx context.Context, notify func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	delay := s.opts.Interval
+	backoff := time.Duration(0)
+	for {
+		select {
+		case <-ctx.Done():
+			select {
+			case <-s.closed:
+				return nil
+			default:
+				return ctx.Err()
+			}
+		case <-time.After(delay):
+		}
+
+		changed, err := s.fetch(ctx, true)
+		if ctx.Err() != nil {
+			continue
+		}
+		s.mu.Lock()
+		s.fresh = s.fresh || changed
+		s.err = err
+		s.mu.Unlock()
+
+		switch {
+		case err != nil:
+			backoff = min(max(2*backoff, time.Second), s.opts.MaxBackoff)
+			delay = backoff
+			notify()
+			continue
+		case changed, backoff > 0:
+			// A reload after a failure reports the recovery.
+			notify()
+		}
+
+		backoff = 0
+		delay = s.opts.Interval
+		if s.opts.LongPoll {
+			delay = 0
+		}
+	}
+}
+
+// fetch requests the document and reports whether it differs from the last
+// one fetched. With conditional set, the request carries the last ETag.
+func (s *HTTPSource) fetch(ctx context.Context, conditional bool) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	etag := s.etag
+	s.mu.Unlock()
+	if conditional && etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("unexpected status '%s'", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := !bytes.Equal(body, s.body)
+	s.etag = resp.Header.Get("ETag")
+	s.body = body
+	return changed, nil
+}
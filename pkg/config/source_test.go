@@ -0,0 +1,270 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chhz0/going/pkg/logger/zlog"
+)
+
+func waitChange[T any](t *testing.T, ch <-chan ChangeEvent[T]) ChangeEvent[T] {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a change notification")
+		return ChangeEvent[T]{}
+	}
+}
+
+func TestConfigV_MemorySource(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "env: dev\nhttp:\n  host: file\n  port: 80\n")
+
+	src := NewMemorySource("json", []byte(`{"http": {"port": 8080}}`))
+	cv, _ := NewConfigV[testConfig]()
+	cv.AddSource(src)
+	defer cv.Close()
+
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if conf := cv.Get(); conf.Http.Host != "file" || conf.Http.Port != 8080 {
+		t.Fatalf("http = %+v, want host from file and port from source", conf.Http)
+	}
+	for _, e := range cv.Explain() {
+		if e.Key == "http.port" && e.Source != "memory" {
+			t.Errorf("source of http.port = %q, want memory", e.Source)
+		}
+	}
+
+	changed := make(chan ChangeEvent[testConfig], 1)
+	cv.SetOnChange(func(e ChangeEvent[testConfig]) { changed <- e })
+	startWatch(t, cv)
+
+	// Watch starts asynchronously; keep setting until the watcher sees it.
+	go func() {
+		for {
+			select {
+			case <-time.After(10 * time.Millisecond):
+				src.Set([]byte(`{"http": {"port": 9090}}`))
+			case <-t.Context().Done():
+				return
+			}
+		}
+	}()
+	waitChange(t, changed)
+	if port := cv.Get().Http.Port; port != 9090 {
+		t.Errorf("http.port = %d, want 9090", port)
+	}
+}
+
+func TestMemorySource_WatchStop(t *testing.T) {
+	src := NewMemorySource("yaml", nil)
+	for range 3 {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := src.Watch(ctx, func() {}); err != context.Canceled {
+			t.Fatalf("Watch() error = %v, want context.Canceled", err)
+		}
+	}
+	if n := len(src.watchers); n != 0 {
+		t.Errorf("%d watchers left after Watch returned, want 0", n)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "remote.yaml")
+	writeFile(t, file, "env: staging\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	cv.AddSource(NewFileSource(file))
+	defer cv.Close()
+
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if env := cv.Get().Env; env != "staging" {
+		t.Errorf("env = %q, want staging", env)
+	}
+}
+
+// configServer is a local stand-in for a central configuration service.
+type configServer struct {
+	mu       sync.Mutex
+	version  int
+	body     string
+	failures int // number of requests to fail before serving.
+	requests atomic.Int32
+}
+
+func (s *configServer) set(body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.body = body
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%d"`, s.version)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	fmt.Fprint(w, s.body)
+}
+
+func TestHTTPSource(t *testing.T) {
+	srv := &configServer{}
+	srv.set("env: remote\nhttp:\n  port: 80\n")
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	src := NewHTTPSource(ts.URL, "yaml", HTTPSourceOptions{Interval: 10 * time.Millisecond})
+	cv, _ := NewConfigV[testConfig]()
+	cv.AddSource(src)
+
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if conf := cv.Get(); conf.Env != "remote" || conf.Http.Port != 80 {
+		t.Fatalf("Get() = %+v, want remote config", conf)
+	}
+
+	changed := make(chan ChangeEvent[testConfig], 1)
+	cv.SetOnChange(func(e ChangeEvent[testConfig]) { changed <- e })
+	startWatch(t, cv)
+
+	srv.set("env: remote\nhttp:\n  port: 8080\n")
+	e := waitChange(t, changed)
+	if e.New.Http.Port != 8080 {
+		t.Errorf("http.port = %d, want 8080", e.New.Http.Port)
+	}
+
+	if err := cv.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	n := srv.requests.Load()
+	time.Sleep(50 * time.Millisecond)
+	if got := srv.requests.Load(); got != n {
+		t.Errorf("source kept polling after Close: %d requests, want %d", got, n)
+	}
+}
+
+func TestHTTPSource_ReadAfterWatch(t *testing.T) {
+	srv := &configServer{}
+	srv.set("env: v1\n")
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	src := NewHTTPSource(ts.URL, "yaml", HTTPSourceOptions{Interval: 10 * time.Millisecond})
+	defer src.Close()
+	if _, err := src.Read(context.Background()); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	type read struct {
+		body     string
+		requests int32
+	}
+	reads := make(chan read, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		src.Watch(ctx, func() {
+			before := srv.requests.Load()
+			body, _ := src.Read(ctx)
+			reads <- read{string(body), srv.requests.Load() - before}
+		})
+	}()
+
+	srv.set("env: v2\n")
+	select {
+	case r := <-reads:
+		if r.body != "env: v2\n" || r.requests != 0 {
+			t.Errorf("Read() in notify = %q with %d requests, want the watched document without a request", r.body, r.requests)
+		}
+	case <-ctx.Done():
+		t.Fatal("no notification after the document changed")
+	}
+	cancel()
+	<-done
+
+	before := srv.requests.Load()
+	if _, err := src.Read(context.Background()); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got := srv.requests.Load() - before; got != 1 {
+		t.Errorf("second Read() sent %d requests, want 1", got)
+	}
+}
+
+func TestHTTPSource_Backoff(t *testing.T) {
+	srv := &configServer{}
+	srv.set("env: remote\n")
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	src := NewHTTPSource(ts.URL, "yaml", HTTPSourceOptions{
+		Interval:   time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+		LongPoll:   true,
+	})
+	cv, _ := NewConfigV[testConfig]()
+	cv.AddSource(src)
+	cv.SetLogger(zlog.New(io.Discard, zlog.InfoLevel, zlog.JSONEncoder))
+	defer cv.Close()
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	srv.mu.Lock()
+	srv.failures = 2
+	srv.mu.Unlock()
+	srv.set("env: recovered\n")
+
+	changed := make(chan ChangeEvent[testConfig], 1)
+	cv.SetOnChange(func(e ChangeEvent[testConfig]) { changed <- e })
+	errs := startWatch(t, cv)
+	for range 2 {
+		select {
+		case err := <-errs:
+			if !strings.Contains(err.Error(), "503") {
+				t.Errorf("Watch() error = %v, want the failed request", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no error reported for a failed request")
+		}
+	}
+	if e := waitChange(t, changed); e.New.Env != "recovered" {
+		t.Errorf("env = %q after the server recovered, want recovered", e.New.Env)
+	}
+	if st := cv.Status(); st.Failures != 2 || st.LastError == nil {
+		t.Errorf("Status() = %+v, want 2 failures", st)
+	}
+}
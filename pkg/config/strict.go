@@ -0,0 +1,177 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// StrictMode selects how keys in config files and sources that map to no
+// field of the config struct are handled, e.g. a misspelled "mysq:".
+type StrictMode int
+
+const (
+	// StrictOff ignores unknown keys. This is the default.
+	StrictOff StrictMode = iota
+	// StrictWarn logs unknown keys, see SetLogger, and loads the config
+	// anyway, for gradual adoption of StrictError.
+	StrictWarn
+	// StrictError rejects a config with unknown keys.
+	StrictError
+)
+
+// SetStrict sets how unknown keys are handled. Keys below fields of map or
+// interface type are never unknown, nor are ProfileKey and VersionKey.
+func (c *ConfigV[T]) SetStrict(mode StrictMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.strict = mode
+}
+
+// UnknownKeyError reports a key that maps to no field of the config struct.
+type UnknownKeyError struct {
+	Key        string // key path, e.g. "mysq".
+	Suggestion string // closest valid key path, if any is close enough.
+	Source     string // file or source the key came from.
+	File       string // config file the key was read from, if any.
+	Line       int    // 1-based line of the key's value, 0 if unknown.
+	Column     int    // 1-based column of the key's value, 0 if unknown.
+}
+
+func (e *UnknownKeyError) Error() string {
+	msg := " is unknown"
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean '%s'?", e.Suggestion)
+	}
+	return describe(e.Key, e.Source, e.File, e.Line, e.Column, msg)
+}
+
+// checkUnknownKeys reports the unknown keys of the config files and sources
+// as last read, according to the strict mode. The caller must hold c.mu.
+func (c *ConfigV[T]) checkUnknownKeys() error {
+	if c.strict == StrictOff {
+		return nil
+	}
+
+	// leaves are the keys of fields, sections the keys of nested structs.
+	leaves := make(map[string]bool)
+	sections := make(map[string]bool)
+	for _, f := range c.fields() {
+		leaves[f.key] = true
+		parts := strings.Split(f.key, ".")
+		for i := 1; i < len(parts); i++ {
+			sections[strings.Join(parts[:i], ".")] = true
+		}
+	}
+
+	keys := make([]string, 0, len(c.keySources))
+	for key := range c.keySources {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var errs []error
+	reported := make(map[string]bool)
+	for _, key := range keys {
+		unknown := unknownPrefix(key, leaves, sections)
+		if unknown == "" || unknown == ProfileKey || unknown == VersionKey || reported[unknown] {
+			continue
+		}
+		reported[unknown] = true
+
+		e := &UnknownKeyError{
+			Key:        unknown,
+			Suggestion: suggestKey(unknown, leaves, sections),
+			Source:     c.keySources[key],
+		}
+		e.File, e.Line, e.Column = c.position(e.Source, unknown)
+		errs = append(errs, e)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	if c.strict == StrictWarn {
+		for _, err := range errs {
+			c.log().Warnw("[ConfigV] unknown config key", "error", err)
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// unknownPrefix returns the shortest prefix of key that maps to neither a
+// field nor a section, or "" if key is known. Everything below a field is
+// known, since the field may be a map.
+func unknownPrefix(key string, leaves, sections map[string]bool) string {
+	prefix := ""
+	for _, name := range strings.Split(key, ".") {
+		if prefix != "" {
+			prefix += "."
+		}
+		prefix += name
+		if leaves[prefix] {
+			return ""
+		}
+		if !sections[prefix] {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// suggestKey returns the known key closest to the unknown key among its
+// siblings, or "" if none is close enough to be a likely typo.
+func suggestKey(key string, leaves, sections map[string]bool) string {
+	parent, name := "", key
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		parent, name = key[:i+1], key[i+1:]
+	}
+
+	var siblings []string
+	for _, known := range []map[string]bool{leaves, sections} {
+		for k := range known {
+			if rest, ok := strings.CutPrefix(k, parent); ok && !strings.Contains(rest, ".") {
+				siblings = append(siblings, k)
+			}
+		}
+	}
+	slices.Sort(siblings)
+
+	best, bestDist := "", min(max(2, len(name)/3), len(name)-1)+1
+	for _, k := range siblings {
+		if d := editDistance(name, k[len(parent):]); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the optimal string alignment distance of a and b: the
+// number of insertions, deletions, substitutions and transpositions of
+// adjacent characters needed to turn a into b.
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
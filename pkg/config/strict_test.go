@@ -0,0 +1,103 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chhz0/going/pkg/logger/zlog"
+)
+
+type strictConfig struct {
+	Http struct {
+		Host string
+		Port int
+	}
+	Mysql struct {
+		Url string
+	}
+	Labels map[string]string
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"mysq", "mysql", 1},
+		{"hots", "host", 1},
+		{"prot", "port", 1},
+		{"url", "uri", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestConfigV_StrictError(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "env: dev\nhttp:\n  hots: localhost\n  port: 80\nmysq:\n  url: db\nlabels:\n  team: core\nzzz: 1\n")
+
+	cv, _ := NewConfigV[strictConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() without strict mode error = %v", err)
+	}
+
+	cv.SetStrict(StrictError)
+	err := cv.Load(dir, "config", "yaml")
+	if err == nil {
+		t.Fatal("Load() with unknown keys error = nil")
+	}
+
+	var got []*UnknownKeyError
+	for _, leaf := range leafErrors(err) {
+		var ue *UnknownKeyError
+		if errors.As(leaf, &ue) {
+			got = append(got, ue)
+		}
+	}
+	want := []UnknownKeyError{
+		{Key: "http.hots", Suggestion: "http.host", Line: 3, Column: 9},
+		{Key: "mysq", Suggestion: "mysql", Line: 6, Column: 3},
+		{Key: "zzz", Line: 9, Column: 6},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Load() error = %v, want %d unknown keys", err, len(want))
+	}
+	for i, w := range want {
+		if g := got[i]; g.Key != w.Key || g.Suggestion != w.Suggestion || g.File != file || g.Line != w.Line || g.Column != w.Column {
+			t.Errorf("unknown key %d = %+v, want %+v", i, g, w)
+		}
+	}
+	if want := "key 'mysq' is unknown, did you mean 'mysql'?"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Load() error = %v, want %q", err, want)
+	}
+}
+
+func TestConfigV_StrictWarn(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "http:\n  port: 80\nmysq:\n  url: db\n")
+
+	var buf bytes.Buffer
+	cv, _ := NewConfigV[strictConfig]()
+	cv.SetLogger(zlog.New(&buf, zlog.InfoLevel, zlog.JSONEncoder))
+	cv.SetStrict(StrictWarn)
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() in warn mode error = %v", err)
+	}
+
+	if got := cv.Get().Http.Port; got != 80 {
+		t.Errorf("http.port = %d, want 80", got)
+	}
+	if !strings.Contains(buf.String(), "did you mean 'mysql'?") {
+		t.Errorf("log = %q, want a warning about mysq", buf.String())
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import "sync"
+
+// subscription is a change handler registered for a key path prefix.
+type subscription[T any] struct {
+	id     uint64
+	prefix string
+	fn     func(ChangeEvent[T])
+}
+
+// subscribers holds the per-key change subscriptions of a ConfigV.
+type subscribers[T any] struct {
+	mu     sync.Mutex
+	nextID uint64
+	subs   []subscription[T]
+}
+
+// Subscribe registers fn to be called after a reload that changed the key
+// path prefix or any key below it, e.g. Subscribe("mysql", fn) is notified
+// when "mysql.url" changes. An empty prefix subscribes to every change.
+//
+// Any number of handlers may be registered for the same prefix; they are
+// called in registration order, after the SetOnChange callback, with the same
+// restrictions. The returned function removes the subscription and is safe to
+// call more than once.
+func (c *ConfigV[T]) Subscribe(prefix string, fn func(ChangeEvent[T])) (unsubscribe func()) {
+	s := &c.subscribers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	id := s.nextID
+	s.subs = append(s.subs, subscription[T]{id: id, prefix: prefix, fn: fn})
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		for i, sub := range s.subs {
+			if sub.id == id {
+				s.subs = append(s.subs[:i:i], s.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notify calls the change callback and every subscription matching e.
+func (c *ConfigV[T]) notify(e ChangeEvent[T]) {
+	if c.onChange != nil {
+		c.onChange(e)
+	}
+
+	c.subscribers.mu.Lock()
+	subs := c.subscribers.subs
+	c.subscribers.mu.Unlock()
+
+	for _, sub := range subs {
+		if e.Changed(sub.prefix) {
+			sub.fn(e)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestConfigV_Subscribe(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "env: dev\nhttp:\n  port: 80\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var calls []string
+	cv.Subscribe("http", func(ChangeEvent[testConfig]) { calls = append(calls, "http-1") })
+	unsubscribe := cv.Subscribe("http", func(ChangeEvent[testConfig]) { calls = append(calls, "http-2") })
+	cv.Subscribe("env", func(ChangeEvent[testConfig]) { calls = append(calls, "env") })
+	cv.Subscribe("", func(ChangeEvent[testConfig]) { calls = append(calls, "all") })
+
+	writeFile(t, file, "env: dev\nhttp:\n  port: 8080\n")
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if want := []string{"http-1", "http-2", "all"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	calls = nil
+	unsubscribe()
+	unsubscribe()
+
+	writeFile(t, file, "env: prod\nhttp:\n  port: 80\n")
+	if err := cv.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if want := []string{"http-1", "env", "all"}; !slices.Equal(calls, want) {
+		t.Errorf("calls after unsubscribe = %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validator is implemented by config types that need checks beyond the
+// `validate:"..."` struct tags. Validate is called after the tags have been
+// checked successfully.
+type Validator interface {
+	Validate() error
+}
+
+// validate checks cfg against the `validate:"..."` tags of its fields and
+// then calls its Validate method, if any.
+//
+// Supported rules, separated by commas:
+//
+//	required          the value must be set and not be the zero value
+//	min=N, max=N      bounds for numbers, durations and byte sizes, or for the
+//	                  length of strings, slices and maps
+//	oneof=a b c       the value must be one of the space-separated values
+//	url               the value must be an absolute URL
+//	hostport          the value must be a "host:port" pair
+//
+// Zero values are checked like any other, so `validate:"min=1"` rejects a
+// port that is missing or misspelled in the config file; only url and
+// hostport accept an empty string. Rules other than required are skipped for
+// values that are not set at all, i.e. nil pointers and the fields of nil
+// sections.
+func validate[T any](cfg *T, tagName string) error {
+	var errs []error
+
+	root := reflect.ValueOf(cfg)
+	for _, f := range structFields(reflect.TypeFor[T](), tagName) {
+		tag, ok := f.tag.Lookup("validate")
+		if !ok || tag == "" {
+			continue
+		}
+
+		val, set := fieldValue(root, f)
+		for set && val.Kind() == reflect.Pointer {
+			set = !val.IsNil()
+			val = val.Elem()
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			if err := checkRule(val, set, rule); err != nil {
+				errs = append(errs, &ValidationError{Key: f.key, Err: err})
+				break
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if validator, ok := any(cfg).(Validator); ok {
+		return validator.Validate()
+	}
+
+	return nil
+}
+
+// checkRule checks val against rule. set is false if the value is not set
+// at all, in which case val is not valid.
+func checkRule(val reflect.Value, set bool, rule string) error {
+	name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+	if name == "required" {
+		if !set || val.IsZero() {
+			return errors.New("is required")
+		}
+		return nil
+	}
+	if !set || ((name == "url" || name == "hostport") && val.IsZero()) {
+		return nil
+	}
+
+	switch name {
+	case "min", "max":
+		return checkBound(val, name, arg)
+	case "oneof":
+		s := fmt.Sprint(val.Interface())
+		if !slices.Contains(strings.Fields(arg), s) {
+			return &quotedError{msg: fmt.Sprintf("must be one of [%s]", arg), value: s}
+		}
+	case "url":
+		s := val.String()
+		if val.Type() == urlType {
+			u := val.Interface().(url.URL)
+			s = u.String()
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return &quotedError{msg: "must be an absolute url", value: s}
+		}
+	case "hostport":
+		_, port, err := net.SplitHostPort(val.String())
+		if err == nil {
+			_, err = strconv.ParseUint(port, 10, 16)
+		}
+		if err != nil {
+			return &quotedError{msg: "must be a host:port pair", value: val.String()}
+		}
+	default:
+		return fmt.Errorf("has unknown validation rule '%s'", name)
+	}
+
+	return nil
+}
+
+// quotedError is a rule violation quoting the offending value, which is
+// redacted if the value is a secret.
+type quotedError struct {
+	msg   string
+	value string
+}
+
+func (e *quotedError) Error() string {
+	return fmt.Sprintf("%s, got '%s'", e.msg, e.value)
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+func checkBound(val reflect.Value, name, arg string) error {
+	var (
+		got   float64
+		bound float64
+		what  = "value"
+		err   error
+	)
+
+	switch {
+	case val.Type() == durationType:
+		var d time.Duration
+		d, err = time.ParseDuration(arg)
+		bound, got = float64(d), float64(val.Int())
+	case val.Type() == byteSizeType:
+		var b ByteSize
+		b, err = ParseByteSize(arg)
+		bound, got = float64(b), float64(val.Uint())
+	case val.CanInt():
+		bound, err = strconv.ParseFloat(arg, 64)
+		got = float64(val.Int())
+	case val.CanUint():
+		bound, err = strconv.ParseFloat(arg, 64)
+		got = float64(val.Uint())
+	case val.CanFloat():
+		bound, err = strconv.ParseFloat(arg, 64)
+		got = val.Float()
+	case val.Kind() == reflect.String, val.Kind() == reflect.Slice, val.Kind() == reflect.Map:
+		bound, err = strconv.ParseFloat(arg, 64)
+		got, what = float64(val.Len()), "length"
+	default:
+		return fmt.Errorf("does not support rule '%s' for type %s", name, val.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("has invalid argument for rule '%s': %w", name, err)
+	}
+
+	if name == "min" && got < bound {
+		return fmt.Errorf("%s must be at least %s", what, arg)
+	}
+	if name == "max" && got > bound {
+		return fmt.Errorf("%s must be at most %s", what, arg)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type validatedConfig struct {
+	Env     string        `validate:"required,oneof=dev test prod"`
+	Timeout time.Duration `validate:"min=1s,max=1m"`
+	Http    struct {
+		Addr string `validate:"hostport"`
+		Port int    `validate:"min=1,max=65535"`
+	}
+	Mysql *struct {
+		Url  string `validate:"required,url"`
+		Tags []string
+	}
+	Name string `validate:"max=5"`
+}
+
+type methodConfig struct {
+	Env   string `validate:"required"`
+	Debug bool
+}
+
+func (c *methodConfig) Validate() error {
+	if c.Env == "prod" && c.Debug {
+		return errors.New("debug must be disabled in prod")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *validatedConfig {
+		c := &validatedConfig{Env: "dev", Timeout: time.Second, Name: "app"}
+		c.Http.Addr = "localhost:80"
+		c.Http.Port = 80
+		c.Mysql = &struct {
+			Url  string `validate:"required,url"`
+			Tags []string
+		}{Url: "mysql://localhost:3306/db"}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *validatedConfig)
+		wantErr string
+	}{
+		{"valid", func(c *validatedConfig) {}, ""},
+		{"required", func(c *validatedConfig) { c.Env = "" }, "key 'env' is required"},
+		{"oneof", func(c *validatedConfig) { c.Env = "stage" }, "key 'env' must be one of"},
+		{"duration min", func(c *validatedConfig) { c.Timeout = time.Millisecond }, "key 'timeout' value must be at least 1s"},
+		{"int max", func(c *validatedConfig) { c.Http.Port = 70000 }, "key 'http.port' value must be at most 65535"},
+		{"zero value", func(c *validatedConfig) { c.Http.Port = 0 }, "key 'http.port' value must be at least 1"},
+		{"empty hostport", func(c *validatedConfig) { c.Http.Addr = "" }, ""},
+		{"hostport", func(c *validatedConfig) { c.Http.Addr = "localhost" }, "key 'http.addr' must be a host:port pair"},
+		{"url", func(c *validatedConfig) { c.Mysql.Url = "localhost" }, "key 'mysql.url' must be an absolute url"},
+		{"nil section", func(c *validatedConfig) { c.Mysql = nil }, "key 'mysql.url' is required"},
+		{"length", func(c *validatedConfig) { c.Name = "toolong" }, "key 'name' length must be at most 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(c)
+
+			err := validate(c, "mapstructure")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_URLType(t *testing.T) {
+	type urlConfig struct {
+		Endpoint *url.URL `validate:"url"`
+		Homepage url.URL  `validate:"url"`
+	}
+
+	c := &urlConfig{Endpoint: &url.URL{Scheme: "https", Host: "api.example.com"}}
+	c.Homepage = url.URL{Scheme: "https", Host: "example.com", Path: "/"}
+	if err := validate(c, "mapstructure"); err != nil {
+		t.Errorf("validate() error = %v, want nil", err)
+	}
+
+	c.Homepage = url.URL{Path: "example.com"}
+	if err := validate(c, "mapstructure"); err == nil || !strings.Contains(err.Error(), "key 'homepage' must be an absolute url, got 'example.com'") {
+		t.Errorf("validate() error = %v, want a relative url error", err)
+	}
+}
+
+func TestValidate_Method(t *testing.T) {
+	if err := validate(&methodConfig{Env: "prod"}, "mapstructure"); err != nil {
+		t.Errorf("validate() error = %v, want nil", err)
+	}
+	if err := validate(&methodConfig{Env: "prod", Debug: true}, "mapstructure"); err == nil {
+		t.Error("validate() error = nil, want Validate() error")
+	}
+	if err := validate(&methodConfig{Debug: true}, "mapstructure"); err == nil || !strings.Contains(err.Error(), "is required") {
+		t.Errorf("validate() error = %v, want tag error before Validate()", err)
+	}
+}
+
+func TestConfigV_RejectInvalidReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "env: dev\ntimeout: 1s\nhttp:\n  port: 80\nmysql:\n  url: mysql://db:3306\n")
+
+	cv, _ := NewConfigV[validatedConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	writeFile(t, file, "env: typo\ntimeout: 1s\nhttp:\n  port: 80\nmysql:\n  url: mysql://other:3306\n")
+	if err := cv.Reload(); err == nil {
+		t.Fatal("Reload() error = nil, want validation error")
+	}
+
+	conf := cv.Get()
+	if conf.Env != "dev" || conf.Mysql.Url != "mysql://db:3306" {
+		t.Errorf("Get() after invalid reload = %+v, want last valid config", conf)
+	}
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/chhz0/going/pkg/logger/zlog"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+)
+
+const defaultDebounce = 100 * time.Millisecond
+
+// SetWatchDebounce sets how long Watch waits after the last file event
+// before reloading, so that bursts of events caused by a single edit result
+// in a single reload. Defaults to 100ms.
+func (c *ConfigV[T]) SetWatchDebounce(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.debounce = d
+}
+
+// ReloadStatus reports the outcome of reloads.
+type ReloadStatus struct {
+	Reloads     uint64    // number of successful loads and reloads.
+	Failures    uint64    // number of failed loads and reloads.
+	LastSuccess time.Time // time of the last successful load or reload.
+	LastError   error     // error of the last failed load or reload.
+	LastErrorAt time.Time // time of LastError.
+}
+
+// Status returns the reload status.
+func (c *ConfigV[T]) Status() ReloadStatus {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	return c.status
+}
+
+// SetLogger sets the logger Watch reports reload failures to. The default
+// logger of zlog is used if none is set.
+func (c *ConfigV[T]) SetLogger(l zlog.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = l
+}
+
+// log returns the logger set with SetLogger, or the default logger of zlog.
+// The caller must hold c.mu.
+func (c *ConfigV[T]) log() zlog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return zlog.WithFields()
+}
+
+// watchErrorBuffer is the capacity of the channel returned by Watch.
+const watchErrorBuffer = 16
+
+// Watch watches every config file that contributed to the configuration,
+// including includes and the profile overlay, as well as every source added
+// with AddSource, and reloads on change until ctx is done. A profile overlay
+// created after the last load or reload is picked up too.
+//
+// The directories holding the files are watched rather than the files, so
+// that editors saving via rename and Kubernetes ConfigMap updates, which swap
+// a "..data" symlink, are followed, and the directories of symlink targets
+// are watched too. Events are debounced, see SetWatchDebounce, and a reload
+// only happens when the content of the files actually changed.
+//
+// Reload and watch failures are logged and sent on the returned channel,
+// which is closed once watching has stopped. Errors are dropped while the
+// channel is full; Status always reports the last one.
+func (c *ConfigV[T]) Watch(ctx context.Context) (<-chan error, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("[ConfigV.Watch] failed to create watcher: %w.", err)
+	}
+
+	c.mu.Lock()
+	debounce := c.debounce
+	logger := c.log()
+	sources := slices.Clone(c.sources)
+	c.mu.Unlock()
+
+	errs := make(chan error, watchErrorBuffer)
+	report := func(err error) {
+		logger.Errorw("[ConfigV.Watch] config watch error", "error", err)
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	reload := func() {
+		if err := c.Reload(); err != nil {
+			report(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, src := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := src.Watch(ctx, reload); err != nil && ctx.Err() == nil {
+				report(fmt.Errorf("failed to watch source '%s': %w", src.Name(), err))
+			}
+		}()
+	}
+
+	dirs := c.watchDirs(watcher, nil, report)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer watcher.Close()
+
+		var fire <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				fire = time.After(debounce)
+			case <-fire:
+				fire = nil
+				if _, err := c.ReloadIfChanged(); err != nil {
+					report(err)
+				}
+				dirs = c.watchDirs(watcher, dirs, report)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				report(fmt.Errorf("watcher error: %w", err))
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+
+	return errs, nil
+}
+
+// watchDirs adds the directories of the current config files, and of their
+// symlink targets, to watcher and returns all watched directories. A watch
+// is re-added when its directory was replaced, since fsnotify drops it.
+func (c *ConfigV[T]) watchDirs(watcher *fsnotify.Watcher, watched []string, report func(error)) []string {
+	var dirs []string
+	for _, file := range c.Files() {
+		dirs = append(dirs, filepath.Dir(file))
+		if real, err := filepath.EvalSymlinks(file); err == nil {
+			dirs = append(dirs, filepath.Dir(real))
+		}
+	}
+	slices.Sort(dirs)
+	dirs = slices.Compact(dirs)
+
+	active := watcher.WatchList()
+	for _, dir := range dirs {
+		if slices.Contains(watched, dir) && slices.Contains(active, dir) {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			report(fmt.Errorf("failed to watch '%s': %w", dir, err))
+		}
+	}
+	return dirs
+}
+
+// ReloadIfChanged reloads when the content of the config files differs from
+// the content last read by a load or reload, and reports whether it did.
+// This is what Watch does once file events have settled; call it directly to
+// reload on a schedule or when the files are not on the OS filesystem.
+func (c *ConfigV[T]) ReloadIfChanged() (bool, error) {
+	if !c.filesChanged() {
+		return false, nil
+	}
+	return true, c.Reload()
+}
+
+// filesChanged reports whether the content of the config files differs from
+// the content last read by a load or reload, or whether the profile overlay
+// was created since.
+func (c *ConfigV[T]) filesChanged() bool {
+	c.mu.Lock()
+	fs, missing := c.fs, c.missing
+	c.mu.Unlock()
+
+	for _, file := range missing {
+		if _, err := fs.Stat(file); err == nil {
+			return true
+		}
+	}
+
+	h := sha256.New()
+	for _, file := range c.Files() {
+		data, err := afero.ReadFile(fs, file)
+		if err != nil {
+			// The file is being replaced or was removed; let the reload
+			// decide what to do about it.
+			return true
+		}
+		h.Write([]byte(file))
+		h.Write(data)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return string(h.Sum(nil)) != c.filesHash
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chhz0/going/pkg/logger/zlog"
+)
+
+// waitPort waits for a change event setting http.port to want.
+func waitPort(t *testing.T, changed <-chan ChangeEvent[testConfig], want int) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-changed:
+			if e.New.Http.Port == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for http.port = %d", want)
+		}
+	}
+}
+
+func TestConfigV_FilesChanged(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "http:\n  port: 80\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cv.filesChanged() {
+		t.Error("filesChanged() after Load = true, want false")
+	}
+	writeFile(t, file, "http:\n  port: 80\n")
+	if cv.filesChanged() {
+		t.Error("filesChanged() after rewriting the same content = true, want false")
+	}
+	writeFile(t, file, "http:\n  port: 81\n")
+	if !cv.filesChanged() {
+		t.Error("filesChanged() after a change = false, want true")
+	}
+}
+
+func TestConfigV_FilesChangedOverlayCreated(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "env: prod\nhttp:\n  port: 80\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.prod.yaml"), "http:\n  port: 443\n")
+	changed, err := cv.ReloadIfChanged()
+	if err != nil || !changed {
+		t.Fatalf("ReloadIfChanged() after creating the overlay = %v, %v, want true, nil", changed, err)
+	}
+	if got := cv.Get().Http.Port; got != 443 {
+		t.Errorf("http.port = %d, want 443 from the overlay", got)
+	}
+	if cv.filesChanged() {
+		t.Error("filesChanged() after reading the overlay = true, want false")
+	}
+}
+
+func TestConfigV_WatchDebounce(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "http:\n  port: 80\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	cv.SetWatchDebounce(300 * time.Millisecond)
+
+	changed := make(chan ChangeEvent[testConfig], 16)
+	cv.SetOnChange(func(e ChangeEvent[testConfig]) { changed <- e })
+	startWatch(t, cv)
+
+	for port := 81; port <= 85; port++ {
+		writeFile(t, file, "http:\n  port: "+strconv.Itoa(port)+"\n")
+	}
+	waitPort(t, changed, 85)
+
+	select {
+	case e := <-changed:
+		t.Errorf("got another reload after the burst: %v", e.Keys)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestConfigV_WatchAtomicRename(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "http:\n  port: 80\n")
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	cv.SetWatchDebounce(10 * time.Millisecond)
+
+	changed := make(chan ChangeEvent[testConfig], 16)
+	cv.SetOnChange(func(e ChangeEvent[testConfig]) { changed <- e })
+	startWatch(t, cv)
+
+	for _, port := range []int{81, 82} {
+		tmp := filepath.Join(dir, ".config.yaml.tmp")
+		writeFile(t, tmp, "http:\n  port: "+strconv.Itoa(port)+"\n")
+		if err := os.Rename(tmp, file); err != nil {
+			t.Fatalf("rename: %v", err)
+		}
+		waitPort(t, changed, port)
+	}
+}
+
+func TestConfigV_WatchConfigMapSymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+
+	// Lay out the directory the way the kubelet mounts a ConfigMap.
+	writeFile(t, filepath.Join(dir, "..2025_01", "config.yaml"), "http:\n  port: 80\n")
+	mustSymlink(t, "..2025_01", filepath.Join(dir, "..data"))
+	mustSymlink(t, filepath.Join("..data", "config.yaml"), filepath.Join(dir, "config.yaml"))
+
+	cv, _ := NewConfigV[testConfig]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	cv.SetWatchDebounce(10 * time.Millisecond)
+
+	changed := make(chan ChangeEvent[testConfig], 16)
+	cv.SetOnChange(func(e ChangeEvent[testConfig]) { changed <- e })
+	startWatch(t, cv)
+
+	writeFile(t, filepath.Join(dir, "..2025_02", "config.yaml"), "http:\n  port: 8080\n")
+	mustSymlink(t, "..2025_02", filepath.Join(dir, "..data_tmp"))
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "..2025_01")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	waitPort(t, changed, 8080)
+}
+
+func mustSymlink(t *testing.T, oldname, newname string) {
+	t.Helper()
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+}
+
+// startWatch starts watching until the test ends.
+func startWatch[T any](t *testing.T, cv *ConfigV[T]) <-chan error {
+	t.Helper()
+
+	errs, err := cv.Watch(t.Context())
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	return errs
+}
+
+func TestConfigV_WatchStop(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeFile(t, file, "http:\n  port: 80\n")
+
+	cv, _ := NewConfigV[validatedConfigWatch]()
+	if err := cv.Load(dir, "config", "yaml"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	cv.SetWatchDebounce(10 * time.Millisecond)
+	cv.SetLogger(zlog.New(io.Discard, zlog.InfoLevel, zlog.JSONEncoder))
+
+	for range 3 {
+		ctx, cancel := context.WithCancel(t.Context())
+		errs, err := cv.Watch(ctx)
+		if err != nil {
+			t.Fatalf("Watch() error = %v", err)
+		}
+
+		writeFile(t, file, "http:\n  port: 0\n")
+		select {
+		case err := <-errs:
+			if !strings.Contains(err.Error(), "http.port") {
+				t.Errorf("watch error = %v, want validation error", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no error reported for an invalid change")
+		}
+
+		status := cv.Status()
+		if status.LastError == nil || status.LastErrorAt.Before(status.LastSuccess) || status.Failures == 0 {
+			t.Errorf("Status() = %+v, want the last reload failed", status)
+		}
+
+		cancel()
+		select {
+		case _, ok := <-errs:
+			for ok {
+				_, ok = <-errs
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("error channel not closed after cancel")
+		}
+
+		writeFile(t, file, "http:\n  port: 80\n")
+		if err := cv.Reload(); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+		if status := cv.Status(); status.LastSuccess.Before(status.LastErrorAt) {
+			t.Errorf("Status() = %+v, want the last reload succeeded", status)
+		}
+	}
+}
+
+type validatedConfigWatch struct {
+	Http struct {
+		Port int `validate:"required"`
+	}
+}